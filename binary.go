@@ -0,0 +1,64 @@
+package zlog
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const defaultBinaryMaxBytes = 256
+
+// BinaryData is a field holding raw bytes, see Binary.
+type BinaryData []byte
+
+// Binary returns a field logging data as a hex dump in TextFormatter, and as
+// base64 with its length in JSON formatters:
+//
+//	logger.WithFields(zlog.Binary("packet", buf[:n])).Debug("received")
+func Binary(key string, data []byte) Fields {
+	return Fields{key: BinaryData(data)}
+}
+
+func (d BinaryData) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Length int    `json:"length"`
+		Base64 string `json:"base64"`
+	}{len(d), base64.StdEncoding.EncodeToString(d)})
+}
+
+func (d BinaryData) String() string {
+	if len(d) > 32 {
+		return fmt.Sprintf("%d bytes: %x...", len(d), []byte(d[:32]))
+	}
+	return fmt.Sprintf("%d bytes: %x", len(d), []byte(d))
+}
+
+// writeHexDump prints the offset, hex and ASCII columns of at most maxBytes
+// bytes of d.
+func writeHexDump(b *bytes.Buffer, d BinaryData, prefix string, maxBytes int, color Color) {
+	if maxBytes <= 0 {
+		maxBytes = defaultBinaryMaxBytes
+	}
+	data := []byte(d)
+	if len(data) > maxBytes {
+		data = data[:maxBytes]
+	}
+
+	lines := strings.Split(strings.TrimSuffix(hex.Dump(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = []string{"(empty)"}
+	}
+	if more := len(d) - len(data); more > 0 {
+		lines = append(lines, fmt.Sprintf("... %d more bytes", more))
+	}
+	for _, line := range lines {
+		if color == nocolor {
+			fmt.Fprintf(b, "\n%s%s", prefix, line)
+		} else {
+			fmt.Fprintf(b, "\n%s\x1b[%sm%s\x1b[0m", prefix, color, line)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package zlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHexDump(t *testing.T) {
+	var b bytes.Buffer
+	writeHexDump(&b, BinaryData("Hello, world!\n\x00\x01\x02\x03 and more"), "  ", 20, nocolor)
+	assert.Equal(t, strings.Join([]string{
+		"",
+		"  00000000  48 65 6c 6c 6f 2c 20 77  6f 72 6c 64 21 0a 00 01  |Hello, world!...|",
+		"  00000010  02 03 20 61                                       |.. a|",
+		"  ... 7 more bytes",
+	}, "\n"), b.String())
+
+	b.Reset()
+	writeHexDump(&b, BinaryData{}, "", 0, nocolor)
+	assert.Equal(t, "\n(empty)", b.String())
+}
+
+func TestBinaryRendering(t *testing.T) {
+	packet := New("binary").WithFields(Binary("packet", []byte{0xde, 0xad, 0xbe, 0xef})).Data["packet"]
+
+	assert.Contains(t, renderPlainField("packet", packet), "\n     - packet   = (4 bytes)\n         00000000  de ad be ef  ")
+
+	b, err := json.Marshal(packet)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"length":4,"base64":"3q2+7w=="}`, string(b))
+
+	assert.Equal(t, "4 bytes: deadbeef", BinaryData{0xde, 0xad, 0xbe, 0xef}.String())
+}
@@ -0,0 +1,72 @@
+package zlog
+
+import (
+	"runtime"
+	"strings"
+)
+
+const maximumCallerDepth = 32
+
+// zlogPackage is the import path of this package, used to skip the frames
+// of zlog itself when looking for the code that logged an entry.
+var zlogPackage string
+
+func init() {
+	pc, _, _, _ := runtime.Caller(0)
+	zlogPackage = packageName(runtime.FuncForPC(pc).Name())
+}
+
+// packageName strips the function part from a fully qualified function
+// name, e.g. "github.com/ssor/zlog.(*Logger).Info" becomes
+// "github.com/ssor/zlog".
+func packageName(function string) string {
+	lastSlash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[lastSlash+1:], "."); dot >= 0 {
+		return function[:lastSlash+1+dot]
+	}
+	return function
+}
+
+// isInternalFrame tells whether frame belongs to zlog. It is a variable so
+// that the tests of the package, which log from zlog itself, can report
+// their own frames.
+var isInternalFrame = func(frame runtime.Frame) bool {
+	return packageName(frame.Function) == zlogPackage
+}
+
+// externalCaller returns the first frame on the stack which does not belong
+// to zlog or to one of the packages of outside, or the one skip frames above
+// it. The walk stops at the frames of
+// the runtime, so that nothing is found for the entries logged by the
+// goroutines of zlog itself, which start there.
+func externalCaller(skip int, outside ...string) (runtime.Frame, bool) {
+	pcs := make([]uintptr, maximumCallerDepth)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	found := false
+	for {
+		frame, more := frames.Next()
+		if strings.HasPrefix(frame.Function, "runtime.") {
+			return runtime.Frame{}, false
+		}
+		if found || !isInternalFrame(frame) && !inPackages(frame, outside) {
+			if skip <= 0 {
+				return frame, true
+			}
+			found = true
+			skip--
+		}
+		if !more {
+			return runtime.Frame{}, false
+		}
+	}
+}
+
+func inPackages(frame runtime.Frame, packages []string) bool {
+	for _, p := range packages {
+		if packageName(frame.Function) == p {
+			return true
+		}
+	}
+	return false
+}
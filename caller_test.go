@@ -0,0 +1,116 @@
+package zlog
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCallerLogger(buffer *bytes.Buffer) *Logger {
+	logger := New("caller")
+	logger.Out = buffer
+	logger.Formatter = &TextFormatter{DisableColors: true}
+	return logger
+}
+
+func TestReportCaller(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := newCallerLogger(&buffer)
+
+	logger.Info("no caller")
+	assert.Contains(t, buffer.String(), "(caller)")
+
+	logger.ReportCaller = true
+	buffer.Reset()
+	line := currentLine() + 1
+	logger.Info("caller")
+	assert.Contains(t, buffer.String(), fmt.Sprintf("caller_test.go:%-3d", line))
+
+	buffer.Reset()
+	line = currentLine() + 1
+	logger.WithField("a", 1).Warnf("caller %d", 1)
+	assert.Contains(t, buffer.String(), fmt.Sprintf("caller_test.go:%-3d", line))
+}
+
+func TestEntryCallerExposedToFormatters(t *testing.T) {
+	var input FormatterInput
+	logger := New()
+	logger.ReportCaller = true
+	logger.Formatter = formatterFunc(func(entry FormatterInput) {
+		input = entry
+	})
+
+	line := currentLine() + 1
+	logger.Info("caller")
+	if assert.NotNil(t, input.GetCaller()) {
+		assert.Equal(t, line, input.GetCaller().Line)
+		assert.True(t, strings.HasSuffix(input.GetCaller().Function, "TestEntryCallerExposedToFormatters"))
+	}
+}
+
+func logThroughHelper(logger *Logger, msg string) {
+	logger.Info(msg)
+}
+
+func TestAddCallerSkip(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := newCallerLogger(&buffer)
+	logger.ReportCaller = true
+	skipped := logger.AddCallerSkip(1)
+
+	line := currentLine() + 1
+	logThroughHelper(skipped, "skipped")
+	assert.Contains(t, buffer.String(), fmt.Sprintf("caller_test.go:%-3d", line))
+
+	buffer.Reset()
+	logThroughHelper(logger, "not skipped")
+	assert.NotContains(t, buffer.String(), fmt.Sprintf("caller_test.go:%-3d", line))
+	assert.Equal(t, 0, logger.callerSkip)
+}
+
+func TestAddCallerSkipSharesState(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := newCallerLogger(&buffer)
+	skipped := logger.AddCallerSkip(1).AddCallerSkip(1)
+
+	assert.Contains(t, registeredLoggers(), skipped)
+	assert.Equal(t, 2, skipped.callerSkip)
+
+	var other bytes.Buffer
+	logger.SetOutput(&other)
+	logger.SetLevel(WarnLevel)
+	assert.Equal(t, &other, skipped.Out)
+	assert.Equal(t, WarnLevel, skipped.GetLevel())
+
+	skipped.SetLevel(ErrorLevel)
+	assert.Equal(t, ErrorLevel, logger.GetLevel())
+
+	assert.True(t, logger.Watch("shared") == skipped.Watch("shared"))
+}
+
+func TestHighlightAlwaysReportsCaller(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := newCallerLogger(&buffer)
+
+	line := currentLine() + 1
+	logger.Highlight("here")
+	assert.Contains(t, buffer.String(), fmt.Sprintf("caller_test.go:%-3d", line))
+}
+
+func TestNoCallerFromZlogGoroutines(t *testing.T) {
+	callers := make(chan *runtime.Frame, 1)
+	logger := New()
+	logger.ReportCaller = true
+	logger.Formatter = formatterFunc(func(entry FormatterInput) {
+		callers <- entry.GetCaller()
+	})
+
+	w := logger.Writer()
+	fmt.Fprintln(w, "from the writer")
+	w.Close()
+	assert.Nil(t, <-callers)
+}
@@ -0,0 +1,122 @@
+package zlog
+
+import (
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Command runs an external program with its output logged line by line:
+// stdout at Info and stderr at Warn, each line tagged with the command name,
+// its PID and the stream. Its exit status and run time are logged when it
+// ends.
+//
+//	cmd := logger.Command(exec.Command("make", "all"))
+//	err := cmd.Run()
+type Command struct {
+	cmd     *exec.Cmd
+	entry   *Entry
+	start   time.Time
+	stdout  *pendingWriter
+	stderr  *pendingWriter
+	writers []*io.PipeWriter
+	done    []<-chan struct{}
+}
+
+// Command wraps cmd, which must not be started yet. Writers already set as
+// its Stdout or Stderr still receive the output. The output is only logged
+// once the PID is known, so cmd is started and waited for through the
+// returned Command, never directly.
+func (logger *Logger) Command(cmd *exec.Cmd) *Command {
+	c := &Command{
+		cmd:    cmd,
+		entry:  logger.WithField("cmd", filepath.Base(cmd.Path)),
+		stdout: newPendingWriter(),
+		stderr: newPendingWriter(),
+	}
+	cmd.Stdout = teeWriter(cmd.Stdout, c.stdout)
+	cmd.Stderr = teeWriter(cmd.Stderr, c.stderr)
+	return c
+}
+
+func teeWriter(existing, w io.Writer) io.Writer {
+	if existing == nil {
+		return w
+	}
+	return io.MultiWriter(existing, w)
+}
+
+func (c *Command) Start() error {
+	c.start = time.Now()
+	if err := c.cmd.Start(); err != nil {
+		c.stdout.ready(ioutil.Discard)
+		c.stderr.ready(ioutil.Discard)
+		c.entry.WithError(err).Error("failed to start")
+		return err
+	}
+
+	// The PID is only known now, the output waits for it in the pending
+	// writers.
+	c.entry = c.entry.WithField("pid", c.cmd.Process.Pid)
+	c.stdout.ready(c.streamWriter("stdout", InfoLevel))
+	c.stderr.ready(c.streamWriter("stderr", WarnLevel))
+	return nil
+}
+
+func (c *Command) streamWriter(stream string, level Level) io.Writer {
+	writer, done := c.entry.WithField("stream", stream).writerLevel(level)
+	c.writers = append(c.writers, writer)
+	c.done = append(c.done, done)
+	return writer
+}
+
+// Wait waits for the command to exit and for its output to be logged.
+func (c *Command) Wait() error {
+	err := c.cmd.Wait()
+	for _, writer := range c.writers {
+		writer.Close()
+	}
+	for _, done := range c.done {
+		<-done
+	}
+
+	entry := c.entry.WithFields(Fields{
+		"exit":     c.cmd.ProcessState.ExitCode(),
+		"duration": time.Since(c.start).String(),
+	})
+	if err != nil {
+		entry.WithError(err).Error("exited")
+	} else {
+		entry.Info("exited")
+	}
+	return err
+}
+
+func (c *Command) Run() error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return c.Wait()
+}
+
+// pendingWriter holds writes back until the writer they go to is known.
+type pendingWriter struct {
+	w       io.Writer
+	readyCh chan struct{}
+}
+
+func newPendingWriter() *pendingWriter {
+	return &pendingWriter{readyCh: make(chan struct{})}
+}
+
+func (p *pendingWriter) ready(w io.Writer) {
+	p.w = w
+	close(p.readyCh)
+}
+
+func (p *pendingWriter) Write(b []byte) (int, error) {
+	<-p.readyCh
+	return p.w.Write(b)
+}
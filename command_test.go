@@ -0,0 +1,67 @@
+package zlog
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandOutputIsLogged(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no shell available")
+	}
+
+	logger, entries := newEntryChannelLogger("command", 8)
+	cmd := logger.Command(exec.Command(sh, "-c", "echo out; echo err >&2; head -c 100000 /dev/zero | tr '\\0' x; echo; exit 3"))
+	err = cmd.Run()
+	assert.Error(t, err)
+	close(entries)
+
+	var out, errs, long, exited *loggedEntry
+	for entry := range entries {
+		entry := entry
+		switch {
+		case entry.msg == "out":
+			out = &entry
+		case entry.msg == "err":
+			errs = &entry
+		case strings.HasPrefix(entry.msg, "xxx"):
+			long = &entry
+		case entry.msg == "exited":
+			exited = &entry
+		}
+	}
+
+	if assert.NotNil(t, out) {
+		assert.Equal(t, InfoLevel, out.level)
+		assert.Equal(t, "sh", out.data["cmd"])
+		assert.Equal(t, "stdout", out.data["stream"])
+		assert.Equal(t, cmd.cmd.Process.Pid, out.data["pid"])
+	}
+	if assert.NotNil(t, errs) {
+		assert.Equal(t, WarnLevel, errs.level)
+		assert.Equal(t, "stderr", errs.data["stream"])
+	}
+	if assert.NotNil(t, long) {
+		assert.Len(t, long.msg, 100000)
+	}
+	if assert.NotNil(t, exited) {
+		assert.Equal(t, ErrorLevel, exited.level)
+		assert.Equal(t, 3, exited.data["exit"])
+		_, err := time.ParseDuration(exited.data["duration"].(string))
+		assert.NoError(t, err)
+	}
+}
+
+func TestCommandStartFailure(t *testing.T) {
+	logger, entries := newEntryChannelLogger("command", 1)
+	err := logger.Command(exec.Command("/does/not/exist")).Run()
+	assert.Error(t, err)
+	entry := <-entries
+	assert.Equal(t, "failed to start", entry.msg)
+	assert.Equal(t, "exist", entry.data["cmd"])
+}
@@ -0,0 +1,203 @@
+package zlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DiffOp is one change between two values, as a JSON patch operation (RFC
+// 6902): "add", "remove" or "replace" at a JSON pointer path.
+type DiffOp struct {
+	Op    string
+	Path  string
+	Value interface{}
+	// What was replaced or removed, shown by TextFormatter.
+	Old interface{}
+}
+
+func (op DiffOp) MarshalJSON() ([]byte, error) {
+	if op.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{op.Op, op.Path})
+	}
+	return json.Marshal(struct {
+		Op    string      `json:"op"`
+		Path  string      `json:"path"`
+		Value interface{} `json:"value"`
+	}{op.Op, op.Path, op.Value})
+}
+
+// Diff is the field logged by WithDiff. TextFormatter prints it as - and +
+// lines, JSON formatters as a list of patch operations.
+type Diff []DiffOp
+
+func (d Diff) String() string {
+	var b bytes.Buffer
+	for i, line := range d.lines() {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(line.text)
+	}
+	return b.String()
+}
+
+type diffLine struct {
+	added bool
+	text  string
+}
+
+func (d Diff) lines() []diffLine {
+	lines := make([]diffLine, 0, len(d))
+	for _, op := range d {
+		path := op.Path
+		if path == "" {
+			path = "/"
+		}
+		if op.Op != "add" {
+			lines = append(lines, diffLine{false, fmt.Sprintf("- %s: %s", path, diffValueString(op.Old))})
+		}
+		if op.Op != "remove" {
+			lines = append(lines, diffLine{true, fmt.Sprintf("+ %s: %s", path, diffValueString(op.Value))})
+		}
+	}
+	return lines
+}
+
+func diffValueString(v interface{}) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func writeDiff(b *bytes.Buffer, d Diff, colored bool) {
+	prefix := "         "
+	if len(d) == 0 {
+		fmt.Fprintf(b, "\n%s(no changes)", prefix)
+		return
+	}
+	for _, line := range d.lines() {
+		if !colored {
+			fmt.Fprintf(b, "\n%s%s", prefix, line.text)
+			continue
+		}
+		color := red
+		if line.added {
+			color = green
+		}
+		fmt.Fprintf(b, "\n%s\x1b[%sm%s\x1b[0m", prefix, color, line.text)
+	}
+}
+
+// computeDiff compares before and after once turned into JSON, with the
+// struct encoder of WithStruct. JSON raws given as []byte or json.RawMessage
+// are compared as the JSON they hold. Lists are compared index by index.
+func computeDiff(before, after interface{}, opts structOptions) Diff {
+	d := Diff{}
+	diffValues(&d, "", diffTree(before, opts), diffTree(after, opts))
+	return d
+}
+
+func diffTree(value interface{}, opts structOptions) interface{} {
+	return decodeDiffTree(diffRaw(value, opts))
+}
+
+// diffRaw returns value as JSON, values already holding JSON are kept.
+func diffRaw(value interface{}, opts structOptions) []byte {
+	switch v := value.(type) {
+	case json.RawMessage:
+		return v
+	case []byte:
+		if json.Valid(v) {
+			return v
+		}
+	}
+	return encodeStruct(value, opts)
+}
+
+func decodeDiffTree(raw []byte) interface{} {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return string(raw)
+	}
+	return tree
+}
+
+func diffValues(d *Diff, path string, before, after interface{}) {
+	switch a := before.(type) {
+	case map[string]interface{}:
+		if b, ok := after.(map[string]interface{}); ok {
+			diffObjects(d, path, a, b)
+			return
+		}
+	case []interface{}:
+		if b, ok := after.([]interface{}); ok {
+			diffLists(d, path, a, b)
+			return
+		}
+	}
+	if !reflect.DeepEqual(before, after) {
+		*d = append(*d, DiffOp{Op: "replace", Path: path, Value: after, Old: before})
+	}
+}
+
+func diffObjects(d *Diff, path string, before, after map[string]interface{}) {
+	keys := make([]string, 0, len(before)+len(after))
+	for k := range before {
+		keys = append(keys, k)
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := path + "/" + escapeJSONPointer(k)
+		a, inBefore := before[k]
+		b, inAfter := after[k]
+		switch {
+		case !inAfter:
+			*d = append(*d, DiffOp{Op: "remove", Path: p, Old: a})
+		case !inBefore:
+			*d = append(*d, DiffOp{Op: "add", Path: p, Value: b})
+		default:
+			diffValues(d, p, a, b)
+		}
+	}
+}
+
+// Items are removed from the end, so that the operations apply in order.
+func diffLists(d *Diff, path string, before, after []interface{}) {
+	n := len(before)
+	if len(after) < n {
+		n = len(after)
+	}
+	for i := 0; i < n; i++ {
+		diffValues(d, path+"/"+strconv.Itoa(i), before[i], after[i])
+	}
+	for i := n; i < len(after); i++ {
+		*d = append(*d, DiffOp{Op: "add", Path: path + "/" + strconv.Itoa(i), Value: after[i]})
+	}
+	for i := len(before) - 1; i >= n; i-- {
+		*d = append(*d, DiffOp{Op: "remove", Path: path + "/" + strconv.Itoa(i), Old: before[i]})
+	}
+}
+
+func escapeJSONPointer(s string) string {
+	return strings.Replace(strings.Replace(s, "~", "~0", -1), "/", "~1", -1)
+}
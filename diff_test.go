@@ -0,0 +1,62 @@
+package zlog
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type diffUser struct {
+	Name  string            `json:"name"`
+	Tags  []string          `json:"tags"`
+	Attrs map[string]string `json:"attrs"`
+	Token string            `zlog:"token,redact"`
+}
+
+func TestComputeDiff(t *testing.T) {
+	before := diffUser{Name: "bob", Tags: []string{"a", "b", "c"}, Attrs: map[string]string{"x/y": "1", "old": "2"}, Token: "t1"}
+	after := diffUser{Name: "alice", Tags: []string{"a", "z"}, Attrs: map[string]string{"x/y": "1", "new": "3"}, Token: "t2"}
+
+	assert.Equal(t, Diff{
+		{Op: "add", Path: "/attrs/new", Value: "3"},
+		{Op: "remove", Path: "/attrs/old", Old: "2"},
+		{Op: "replace", Path: "/name", Value: "alice", Old: "bob"},
+		{Op: "replace", Path: "/tags/1", Value: "z", Old: "b"},
+		{Op: "remove", Path: "/tags/2", Old: "c"},
+	}, computeDiff(before, after, structOptions{}))
+}
+
+func TestComputeDiffOfJSONRaws(t *testing.T) {
+	d := computeDiff([]byte(`{"a":[1,2],"b":{"c":true}}`), json.RawMessage(`{"a":[1,2,3],"b":null}`), structOptions{})
+	assert.Equal(t, Diff{
+		{Op: "add", Path: "/a/2", Value: json.Number("3")},
+		{Op: "replace", Path: "/b", Value: nil, Old: map[string]interface{}{"c": true}},
+	}, d)
+
+	assert.Equal(t, Diff{}, computeDiff(map[string]int{"a": 1}, map[string]int{"a": 1}, structOptions{}))
+	assert.Equal(t, Diff{{Op: "replace", Path: "", Value: json.Number("2"), Old: json.Number("1")}}, computeDiff(1, 2, structOptions{}))
+}
+
+func TestDiffRendering(t *testing.T) {
+	entry := New("diff").WithDiff("user", map[string]interface{}{"name": "bob", "age": 30}, map[string]interface{}{"name": "alice", "admin": true})
+	d := entry.Data["user"]
+
+	assert.Equal(t, strings.Join([]string{
+		"\n     - user     =",
+		`         + /admin: true`,
+		`         - /age: 30`,
+		`         - /name: "bob"`,
+		`         + /name: "alice"`,
+	}, "\n"), renderPlainField("user", d))
+
+	b, err := json.Marshal(d)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"op":"add","path":"/admin","value":true},{"op":"remove","path":"/age"},`+
+		`{"op":"replace","path":"/name","value":"alice"}]`, string(b))
+}
+
+func TestEscapeJSONPointer(t *testing.T) {
+	assert.Equal(t, "a~1b~0c", escapeJSONPointer("a/b~c"))
+}
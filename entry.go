@@ -2,14 +2,16 @@ package zlog
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
+	"runtime"
+	"runtime/pprof"
 	"strings"
 	"sync"
 	"time"
 )
 
-//var entryCallDepth = 4
 var bufferPool *sync.Pool
 
 func init() {
@@ -48,6 +50,17 @@ type Entry struct {
 	Buffer *bytes.Buffer
 
 	JsonRawList []byte
+
+	// Where the entry was logged from, set when the logger reports callers
+	// and for highlighted lines
+	Caller *runtime.Frame
+
+	// ID of the goroutine which logged the entry, set when the logger reports
+	// goroutines
+	Goroutine int64
+
+	// The runtime/pprof labels of the context given to WithContext
+	Labels map[string]string
 }
 
 func NewEntry(logger *Logger, moduleName string) *Entry {
@@ -67,7 +80,7 @@ func NewEntry(logger *Logger, moduleName string) *Entry {
 // Returns the string representation from the reader and ultimately the
 // formatter.
 func (entry *Entry) String() (string, error) {
-	serialized, err := entry.Logger.Formatter.Format(entry, 0)
+	serialized, err := entry.Logger.Formatter.Format(entry)
 	if err != nil {
 		return "", err
 	}
@@ -85,8 +98,35 @@ func (entry *Entry) WithField(key string, value interface{}) *Entry {
 	return entry.WithFields(Fields{key: value})
 }
 
+// Add rows as a Table field.
+func (entry *Entry) WithTable(key string, rows interface{}, columns ...string) *Entry {
+	return entry.WithField(key, newTable(rows, columns, entry.Logger.structOptions()))
+}
+
+// Add the changes from before to after as a Diff field.
+func (entry *Entry) WithDiff(key string, before, after interface{}) *Entry {
+	return entry.WithField(key, computeDiff(before, after, entry.Logger.structOptions()))
+}
+
 func (entry *Entry) WithJsonRaw(bs []byte) *Entry {
-	return &Entry{Logger: entry.Logger, Data: entry.Data, JsonRawList: bs}
+	return &Entry{Logger: entry.Logger, Data: entry.Data, JsonRawList: bs, Labels: entry.Labels}
+}
+
+// Add the runtime/pprof labels of ctx, set with pprof.Do or
+// pprof.WithLabels, to the Entry.
+func (entry *Entry) WithContext(ctx context.Context) *Entry {
+	labels := make(map[string]string, len(entry.Labels))
+	for k, v := range entry.Labels {
+		labels[k] = v
+	}
+	pprof.ForLabels(ctx, func(key, value string) bool {
+		labels[key] = value
+		return true
+	})
+	if len(labels) == 0 {
+		labels = nil
+	}
+	return &Entry{Logger: entry.Logger, Data: entry.Data, JsonRawList: entry.JsonRawList, Labels: labels}
 }
 
 // Add a map of fields to the Entry.
@@ -98,7 +138,7 @@ func (entry *Entry) WithFields(fields Fields) *Entry {
 	for k, v := range fields {
 		data[k] = v
 	}
-	return &Entry{Logger: entry.Logger, Data: data}
+	return &Entry{Logger: entry.Logger, Data: data, Labels: entry.Labels}
 }
 
 func (entry *Entry) WithMultiLines(key, longStr string) *Entry {
@@ -113,7 +153,7 @@ func (entry *Entry) WithMultiLines(key, longStr string) *Entry {
 		}
 		data[fmt.Sprintf("%s-%d", key, index)] = ln
 	}
-	return &Entry{Logger: entry.Logger, Data: data}
+	return &Entry{Logger: entry.Logger, Data: data, Labels: entry.Labels}
 }
 
 func (entry *Entry) WithLongString(key, longStr, sep string) *Entry {
@@ -121,31 +161,66 @@ func (entry *Entry) WithLongString(key, longStr, sep string) *Entry {
 	return entry.WithMultiLines(key, longStr)
 }
 
+// The data is copied, entries share theirs with the entries they were
+// derived from. A stack trace given explicitly is kept.
+func (entry *Entry) withStackTrace() Fields {
+	if _, ok := entry.Data[StackTraceKey]; ok {
+		return entry.Data
+	}
+	data := make(Fields, len(entry.Data)+1)
+	for k, v := range entry.Data {
+		data[k] = v
+	}
+	data[StackTraceKey] = captureStackTrace(entry.Logger.callerSkip, entry.Logger.StackTraceFilter)
+	return data
+}
+
+func (entry Entry) logWithCaller(level Level, msg string) {
+	entry.Caller = entry.Logger.getCaller()
+	entry.log(level, msg)
+}
+
 // This function is not declared with a pointer value because otherwise
 // race conditions will occur when using multiple goroutines
-func (entry Entry) log(callDepth int, level Level, msg string) {
+func (entry Entry) log(level Level, msg string) {
 	var buffer *bytes.Buffer
+	if entry.Logger.ReportGoroutine || entry.Logger.OnlyGoroutine != 0 {
+		entry.Goroutine = currentGoroutineID()
+		if only := entry.Logger.OnlyGoroutine; only != 0 && entry.Goroutine != only {
+			return
+		}
+	}
+	if entry.Caller == nil && entry.Logger.ReportCaller {
+		entry.Caller = entry.Logger.getCaller()
+	}
+	if entry.Logger.ReportStackTrace && level <= entry.Logger.StackTraceLevel {
+		entry.Data = entry.withStackTrace()
+	}
 	entry.Time = time.Now()
 	entry.Level = level
 	entry.Message = msg
+	if len(entry.Logger.RedactRules) > 0 {
+		entry.redact(entry.Logger.RedactRules)
+	}
 
 	buffer = bufferPool.Get().(*bytes.Buffer)
 	buffer.Reset()
 	defer bufferPool.Put(buffer)
 	entry.Buffer = buffer
-	serialized, err := entry.Logger.Formatter.Format(&entry, callDepth)
+	serialized, err := entry.Logger.Formatter.Format(&entry)
 	entry.Buffer = nil
+	mu := &entry.Logger.root().mu
 	if err != nil {
-		entry.Logger.mu.Lock()
+		mu.Lock()
 		fmt.Fprintf(os.Stderr, "Failed to obtain reader, %v\n", err)
-		entry.Logger.mu.Unlock()
+		mu.Unlock()
 	} else {
-		entry.Logger.mu.Lock()
+		mu.Lock()
 		_, err = entry.Logger.Out.Write(serialized)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to write to log, %v\n", err)
 		}
-		entry.Logger.mu.Unlock()
+		mu.Unlock()
 	}
 
 	// To avoid Entry#log() returning a value that only would make sense for
@@ -156,9 +231,15 @@ func (entry Entry) log(callDepth int, level Level, msg string) {
 	//}
 }
 
+func (entry *Entry) logLevel(level Level, msg string) {
+	if entry.Logger.Level >= level {
+		entry.log(level, msg)
+	}
+}
+
 func (entry *Entry) Debug(args ...interface{}) {
 	if entry.Logger.Level >= DebugLevel {
-		entry.log(0, DebugLevel, fmt.Sprint(args...))
+		entry.log(DebugLevel, fmt.Sprint(args...))
 	}
 }
 
@@ -168,13 +249,13 @@ func (entry *Entry) Print(args ...interface{}) {
 
 func (entry *Entry) Info(args ...interface{}) {
 	if entry.Logger.Level >= InfoLevel {
-		entry.log(0, InfoLevel, fmt.Sprint(args...))
+		entry.log(InfoLevel, fmt.Sprint(args...))
 	}
 }
 
 func (entry *Entry) Warn(args ...interface{}) {
 	if entry.Logger.Level >= WarnLevel {
-		entry.log(0, WarnLevel, fmt.Sprint(args...))
+		entry.log(WarnLevel, fmt.Sprint(args...))
 	}
 }
 
@@ -184,19 +265,19 @@ func (entry *Entry) Warning(args ...interface{}) {
 
 func (entry *Entry) Error(args ...interface{}) {
 	if entry.Logger.Level >= ErrorLevel {
-		entry.log(0, ErrorLevel, fmt.Sprint(args...))
+		entry.log(ErrorLevel, fmt.Sprint(args...))
 	}
 }
 
 func (entry *Entry) Fatal(args ...interface{}) {
 	if entry.Logger.Level >= FatalLevel {
-		entry.log(0, FatalLevel, fmt.Sprint(args...))
+		entry.log(FatalLevel, fmt.Sprint(args...))
 	}
 }
 
 func (entry *Entry) Panic(args ...interface{}) {
 	if entry.Logger.Level >= PanicLevel {
-		entry.log(0, PanicLevel, fmt.Sprint(args...))
+		entry.log(PanicLevel, fmt.Sprint(args...))
 	}
 	//panic(fmt.Sprint(args...))
 }
@@ -324,3 +405,15 @@ func (entry *Entry) GetData() Fields {
 func (entry *Entry) GetJsonRaw() []byte {
 	return entry.JsonRawList
 }
+
+func (entry *Entry) GetCaller() *runtime.Frame {
+	return entry.Caller
+}
+
+func (entry *Entry) GetGoroutine() int64 {
+	return entry.Goroutine
+}
+
+func (entry *Entry) GetLabels() map[string]string {
+	return entry.Labels
+}
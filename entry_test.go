@@ -22,7 +22,7 @@ func TestEntryWithError(t *testing.T) {
 
 	logger := New()
 	logger.Out = &bytes.Buffer{}
-	entry := NewEntry(logger)
+	entry := NewEntry(logger, logger.Name())
 
 	assert.Equal(err, entry.WithError(err).Data["error"])
 
@@ -35,43 +35,28 @@ func TestEntryWithError(t *testing.T) {
 func TestEntryPanicln(t *testing.T) {
 	errBoom := fmt.Errorf("boom time")
 
-	defer func() {
-		p := recover()
-		assert.NotNil(t, p)
-
-		switch pVal := p.(type) {
-		case *Entry:
-			assert.Equal(t, "kaboom", pVal.Message)
-			assert.Equal(t, errBoom, pVal.Data["err"])
-		default:
-			t.Fatalf("want type *Entry, got %T: %#v", pVal, pVal)
-		}
-	}()
-
 	logger := New()
-	logger.Out = &bytes.Buffer{}
-	entry := NewEntry(logger)
-	entry.WithField("err", errBoom).Panicln("kaboom")
+	var logged FormatterInput
+	logger.Formatter = formatterFunc(func(entry FormatterInput) { logged = entry })
+	entry := NewEntry(logger, logger.Name())
+
+	// zlog logs panic entries without panicking.
+	assert.NotPanics(t, func() { entry.WithField("err", errBoom).Panicln("kaboom") })
+	assert.Equal(t, PanicLevel, logged.GetLevel())
+	assert.Equal(t, "kaboom", logged.GetMessage())
+	assert.Equal(t, errBoom, logged.GetData()["err"])
 }
 
 func TestEntryPanicf(t *testing.T) {
 	errBoom := fmt.Errorf("boom again")
 
-	defer func() {
-		p := recover()
-		assert.NotNil(t, p)
-
-		switch pVal := p.(type) {
-		case *Entry:
-			assert.Equal(t, "kaboom true", pVal.Message)
-			assert.Equal(t, errBoom, pVal.Data["err"])
-		default:
-			t.Fatalf("want type *Entry, got %T: %#v", pVal, pVal)
-		}
-	}()
-
 	logger := New()
-	logger.Out = &bytes.Buffer{}
-	entry := NewEntry(logger)
-	entry.WithField("err", errBoom).Panicf("kaboom %v", true)
+	var logged FormatterInput
+	logger.Formatter = formatterFunc(func(entry FormatterInput) { logged = entry })
+	entry := NewEntry(logger, logger.Name())
+
+	assert.NotPanics(t, func() { entry.WithField("err", errBoom).Panicf("kaboom %v", true) })
+	assert.Equal(t, PanicLevel, logged.GetLevel())
+	assert.Equal(t, "kaboom true", logged.GetMessage())
+	assert.Equal(t, errBoom, logged.GetData()["err"])
 }
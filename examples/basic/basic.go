@@ -45,7 +45,7 @@ func main() {
     modulePrint(log)
 
     printTitle("ChildLog")
-    child := log.Sub("basic")
+    child := zlog.New("main", "basic")
     child.Info("Info: This a child log")
 
     printTitle("DumpStacks")
@@ -19,7 +19,11 @@ func main() {
 
 	var loggers []*zlog.Logger
 	for _, name := range []string{"module1", "module2", "module3"} {
-		loggers = append(loggers, zlog.New(name))
+		logger := zlog.New(name)
+		// Tell the modules apart by their color, with `FORCE_COLOR=1` when
+		// reading main.log with `less -R`, and line them up.
+		logger.Formatter = &zlog.TextFormatter{ColorModules: true, ModuleWidth: 8}
+		loggers = append(loggers, logger)
 	}
 
 	zlog.SetOutput(logFile)
@@ -1,40 +1,43 @@
 package zlog
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"strings"
+	"sync"
 )
 
 var (
 	// std is the name of the standard logger in stdlib `log`
-	std                      *Logger
-	exportedDefaultCallDepth = 6
-	loggers                  = []*Logger{}
+	std     *Logger
+	stdOnce sync.Once
+	// All the loggers created by New, guarded by loggersMu.
+	loggers   = []*Logger{}
+	loggersMu sync.Mutex
 )
 
 func StandardLogger() *Logger {
-	if std == nil {
+	stdOnce.Do(func() {
 		std = New()
-		loggers = append(loggers, std)
-	}
+	})
 	return std
 }
 
-// SetOutput sets the standard logger output.
+// SetOutput sets the output of all the loggers.
 func SetOutput(out io.Writer) {
-	for _, logger := range loggers {
+	for _, logger := range registeredLoggers() {
 		logger.SetOutput(out)
 	}
 }
 
+// SetPrintLineNumber toggles the file:line of the stdlib `log` package, which
+// RedirectStdLog turns into the caller of the entries.
 func SetPrintLineNumber(b bool) {
 	if b {
-		log.SetFlags(log.LstdFlags | log.Lshortfile)
+		setStdLogFlags(log.LstdFlags | log.Lshortfile)
 	} else {
-		log.SetFlags(log.LstdFlags)
+		setStdLogFlags(log.LstdFlags)
 	}
 }
 
@@ -47,7 +50,7 @@ func SetLevel(level Level) {
 // GetLevel returns the standard logger level.
 func GetLevel() Level {
 	logger := StandardLogger()
-	return logger.Level
+	return logger.GetLevel()
 }
 
 // WithError creates an entry from the standard logger and adds an error to it, using the value defined in ErrorKey as key.
@@ -56,8 +59,10 @@ func WithError(err error) *Entry {
 	return logger.WithField(ErrorKey, err)
 }
 
+// DumpStacks logs the stacks of all goroutines on the standard logger, see
+// `Logger.DumpGoroutines` to select some of them.
 func DumpStacks() {
-	dumpStacks()
+	StandardLogger().DumpGoroutines(GoroutineFilter{})
 }
 
 // WithField creates an entry from the standard logger and adds a field to
@@ -99,12 +104,22 @@ func WithLongString(key, longStr, sep string) *Entry {
 	return entry
 }
 
+// WithDiff creates an entry from the standard logger with the changes from
+// before to after.
+func WithDiff(key string, before, after interface{}) *Entry {
+	logger := StandardLogger()
+	return logger.WithDiff(key, before, after)
+}
+
+// WithTable creates an entry from the standard logger with rows as a table.
+func WithTable(key string, rows interface{}, columns ...string) *Entry {
+	logger := StandardLogger()
+	return logger.WithTable(key, rows, columns...)
+}
+
 func WithStruct(value interface{}) *Entry {
-	bs, err := json.Marshal(value)
-	if err != nil {
-		return WithError(err)
-	}
-	return WithJsonRaw(bs)
+	logger := StandardLogger()
+	return logger.WithStruct(value)
 }
 
 // WithFields creates an entry from the standard logger and adds multiple
@@ -201,13 +216,13 @@ func Successf(format string, args ...interface{}) {
 func Highlight(args ...interface{}) {
 	logger := StandardLogger()
 
-	logger.highlight(exportedDefaultCallDepth, args...)
+	logger.highlight(args...)
 }
 
 func Highlightf(format string, args ...interface{}) {
 	logger := StandardLogger()
 
-	logger.highlight(exportedDefaultCallDepth, fmt.Sprintf(format, args...))
+	logger.highlight(fmt.Sprintf(format, args...))
 }
 
 // Warn logs a message at level Warn on the standard logger.
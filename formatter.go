@@ -1,8 +1,9 @@
 package zlog
 
 import (
-    "time"
     "bytes"
+    "runtime"
+    "time"
 )
 
 const DefaultTimestampFormat = "2006-01-02 15:04:05"
@@ -14,6 +15,9 @@ type FormatterInput interface {
     GetMessage() string
     GetLevel() Level
     GetJsonRaw() []byte
+    GetCaller() *runtime.Frame
+    GetGoroutine() int64
+    GetLabels() map[string]string
 }
 
 // The Formatter interface is used to implement a custom Formatter. It takes an
@@ -27,7 +31,7 @@ type FormatterInput interface {
 // `entry.Data`. Format is expected to return an array of bytes which are then
 // logged to `logger.Out`.
 type Formatter interface {
-    Format(input FormatterInput, callDepth int) ([]byte, error)
+    Format(input FormatterInput) ([]byte, error)
 }
 
 // This is to not silently overwrite `time`, `msg` and `level` fields when
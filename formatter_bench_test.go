@@ -82,7 +82,7 @@ func doBenchmark(b *testing.B, formatter Formatter, fields Fields) {
 	var d []byte
 	var err error
 	for i := 0; i < b.N; i++ {
-		d, err = formatter.Format(entry,0)
+		d, err = formatter.Format(entry)
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -1,8 +1,19 @@
 module github.com/ssor/zlog
 
-go 1.12
+go 1.18
 
 require (
+	github.com/go-logr/logr v1.4.2
 	github.com/stretchr/testify v1.3.0
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0
 )
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+)
+
+require (
+	github.com/BurntSushi/toml v1.6.0 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+)
@@ -0,0 +1,282 @@
+package zlog
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Goroutine is a goroutine as found in a dump of all stacks.
+type Goroutine struct {
+	ID int64
+	// What the goroutine is doing, e.g. "running", "chan receive" or "select"
+	State string
+	// How long the goroutine has been blocked, the runtime only reports it
+	// in minutes from the first minute on
+	Wait time.Duration
+	// Whether the goroutine is locked to its OS thread
+	LockedToThread bool
+	Stack          StackTrace
+	// The go statement which started the goroutine, nil for the main goroutine
+	CreatedBy *StackFrame
+}
+
+// GoroutineGroup gathers the goroutines which are in the same state with the
+// same stack, like the workers of a pool waiting for jobs.
+type GoroutineGroup struct {
+	IDs       []int64
+	State     string
+	Wait      time.Duration
+	Stack     StackTrace
+	CreatedBy *StackFrame
+}
+
+func (group *GoroutineGroup) Count() int {
+	return len(group.IDs)
+}
+
+// GoroutineFilter selects the goroutines of a dump, the zero value selects
+// all of them.
+type GoroutineFilter struct {
+	// Only goroutines with a frame in one of these packages, matched as
+	// import path prefixes
+	Packages []string
+	// Only goroutines in one of these states
+	States []string
+	// Only goroutines blocked for at least this long
+	MinWait time.Duration
+}
+
+func (filter GoroutineFilter) matches(g *Goroutine) bool {
+	if g.Wait < filter.MinWait {
+		return false
+	}
+	if len(filter.States) > 0 && !containsString(filter.States, g.State) {
+		return false
+	}
+	if len(filter.Packages) == 0 {
+		return true
+	}
+	for _, frame := range g.Stack {
+		pkg := packageName(frame.Function)
+		for _, prefix := range filter.Packages {
+			if strings.HasPrefix(pkg, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Goroutines returns the goroutines currently alive, the calling one first.
+func Goroutines() []*Goroutine {
+	return parseGoroutines(allStacks())
+}
+
+// allStacks grows its buffer until the dump of all goroutines fits.
+func allStacks() []byte {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// currentGoroutineID reads the ID of the calling goroutine from the header
+// of its stack.
+func currentGoroutineID() int64 {
+	var buf [64]byte
+	fields := bytes.Fields(buf[:runtime.Stack(buf[:], false)])
+	if len(fields) < 2 {
+		return 0
+	}
+	id, _ := strconv.ParseInt(string(fields[1]), 10, 64)
+	return id
+}
+
+var goroutineHeader = regexp.MustCompile(`^goroutine (\d+) \[([^\]]*)\]:$`)
+
+// parseGoroutines reads the format of runtime.Stack, which is also the one
+// of panics:
+//
+//	goroutine 7 [chan receive, 3 minutes]:
+//	main.worker(0xc000010000)
+//		/src/app/main.go:42 +0x45
+//	created by main.main in goroutine 1
+//		/src/app/main.go:17 +0x7f
+func parseGoroutines(dump []byte) []*Goroutine {
+	var goroutines []*Goroutine
+	var g *Goroutine
+	var function string
+	createdBy := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(dump))
+	scanner.Buffer(make([]byte, 0, 4096), len(dump)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := goroutineHeader.FindStringSubmatch(line); m != nil {
+			g = &Goroutine{}
+			g.ID, _ = strconv.ParseInt(m[1], 10, 64)
+			parseGoroutineState(g, m[2])
+			goroutines = append(goroutines, g)
+			function = ""
+			continue
+		}
+		if g == nil || len(line) == 0 {
+			continue
+		}
+
+		if line[0] == '\t' {
+			if function == "" {
+				continue
+			}
+			frame := parseFrameLocation(function, strings.TrimSpace(line))
+			if createdBy {
+				g.CreatedBy = &frame
+			} else {
+				g.Stack = append(g.Stack, frame)
+			}
+			function = ""
+			continue
+		}
+
+		createdBy = strings.HasPrefix(line, "created by ")
+		if createdBy {
+			function = strings.TrimPrefix(line, "created by ")
+			if i := strings.Index(function, " in goroutine "); i >= 0 {
+				function = function[:i]
+			}
+		} else if strings.HasPrefix(line, "...") {
+			// "...additional frames elided..."
+			function = ""
+		} else if i := strings.LastIndex(line, "("); i > 0 {
+			function = line[:i]
+		} else {
+			function = line
+		}
+	}
+	return goroutines
+}
+
+func parseGoroutineState(g *Goroutine, s string) {
+	for i, part := range strings.Split(s, ", ") {
+		switch {
+		case i == 0:
+			g.State = part
+		case part == "locked to thread":
+			g.LockedToThread = true
+		case strings.HasSuffix(part, " minutes"):
+			minutes, err := strconv.Atoi(strings.TrimSuffix(part, " minutes"))
+			if err == nil {
+				g.Wait = time.Duration(minutes) * time.Minute
+			}
+		}
+	}
+}
+
+// parseFrameLocation reads "/src/app/main.go:42 +0x45".
+func parseFrameLocation(function, location string) StackFrame {
+	frame := StackFrame{Function: function, File: location}
+	if i := strings.LastIndex(location, " +0x"); i >= 0 {
+		location = location[:i]
+	}
+	if i := strings.LastIndex(location, ":"); i >= 0 {
+		if line, err := strconv.Atoi(location[i+1:]); err == nil {
+			frame.File = location[:i]
+			frame.Line = line
+		}
+	}
+	return frame
+}
+
+// GroupGoroutines merges the goroutines sharing state and stack, the
+// largest groups first.
+func GroupGoroutines(goroutines []*Goroutine) []*GoroutineGroup {
+	var groups []*GoroutineGroup
+	index := make(map[string]*GoroutineGroup)
+	for _, g := range goroutines {
+		key := g.State + "\n" + g.Stack.String()
+		if g.CreatedBy != nil {
+			key += "\n" + g.CreatedBy.String()
+		}
+		group, ok := index[key]
+		if !ok {
+			group = &GoroutineGroup{State: g.State, Stack: g.Stack, CreatedBy: g.CreatedBy}
+			index[key] = group
+			groups = append(groups, group)
+		}
+		group.IDs = append(group.IDs, g.ID)
+		if g.Wait > group.Wait {
+			group.Wait = g.Wait
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Count() > groups[j].Count()
+	})
+	return groups
+}
+
+// DumpGoroutines logs the goroutines selected by filter, one entry for each
+// group of identical goroutines, which is the first thing to look at when a
+// program hangs.
+func (logger *Logger) DumpGoroutines(filter GoroutineFilter) {
+	all := Goroutines()
+	var selected []*Goroutine
+	for _, g := range all {
+		if filter.matches(g) {
+			selected = append(selected, g)
+		}
+	}
+	groups := GroupGoroutines(selected)
+
+	// A dump is asked for explicitly, it is logged whatever the level.
+	logger.WithFields(Fields{
+		"total":    len(all),
+		"selected": len(selected),
+	}).log(InfoLevel, fmt.Sprintf("goroutine dump: %d groups", len(groups)))
+
+	for _, group := range groups {
+		fields := Fields{
+			"count":       group.Count(),
+			"goroutines":  formatGoroutineIDs(group.IDs, 10),
+			StackTraceKey: group.Stack,
+		}
+		if group.Wait > 0 {
+			fields["wait"] = group.Wait.String()
+		}
+		if group.CreatedBy != nil {
+			fields["created_by"] = group.CreatedBy.String()
+		}
+		logger.WithFields(fields).log(InfoLevel, fmt.Sprintf("%d x [%s]", group.Count(), group.State))
+	}
+}
+
+func formatGoroutineIDs(ids []int64, max int) string {
+	parts := make([]string, 0, max+1)
+	for i, id := range ids {
+		if i == max {
+			parts = append(parts, fmt.Sprintf("... %d more", len(ids)-max))
+			break
+		}
+		parts = append(parts, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,193 @@
+package zlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleDump = `goroutine 1 [running]:
+main.main()
+	/src/app/main.go:10 +0x1d
+
+goroutine 7 [chan receive, 3 minutes]:
+main.worker(0xc000010000)
+	/src/app/main.go:42 +0x45
+created by main.main in goroutine 1
+	/src/app/main.go:17 +0x7f
+
+goroutine 8 [chan receive, 5 minutes]:
+main.worker(0xc000010008)
+	/src/app/main.go:42 +0x45
+created by main.main in goroutine 1
+	/src/app/main.go:17 +0x7f
+
+goroutine 9 [syscall, locked to thread]:
+syscall.Syscall(0x0, 0x1, 0x2)
+	/usr/lib/go/src/syscall/syscall_linux.go:69 +0x25
+main.(*reader).read(...)
+	/src/app/reader.go:8
+created by main.main
+	/src/app/main.go:18 +0x99
+`
+
+func TestParseGoroutines(t *testing.T) {
+	goroutines := parseGoroutines([]byte(sampleDump))
+	if !assert.Len(t, goroutines, 4) {
+		return
+	}
+
+	assert.Equal(t, int64(1), goroutines[0].ID)
+	assert.Equal(t, "running", goroutines[0].State)
+	assert.Equal(t, StackTrace{{Function: "main.main", File: "/src/app/main.go", Line: 10}}, goroutines[0].Stack)
+	assert.Nil(t, goroutines[0].CreatedBy)
+
+	assert.Equal(t, "chan receive", goroutines[1].State)
+	assert.Equal(t, 3*time.Minute, goroutines[1].Wait)
+	assert.Equal(t, &StackFrame{Function: "main.main", File: "/src/app/main.go", Line: 17}, goroutines[1].CreatedBy)
+
+	assert.Equal(t, "syscall", goroutines[3].State)
+	assert.True(t, goroutines[3].LockedToThread)
+	assert.Equal(t, StackFrame{Function: "main.(*reader).read", File: "/src/app/reader.go", Line: 8}, goroutines[3].Stack[1])
+}
+
+func TestGroupAndFilterGoroutines(t *testing.T) {
+	goroutines := parseGoroutines([]byte(sampleDump))
+
+	groups := GroupGoroutines(goroutines)
+	if assert.Len(t, groups, 3) {
+		assert.Equal(t, []int64{7, 8}, groups[0].IDs)
+		assert.Equal(t, 5*time.Minute, groups[0].Wait)
+	}
+
+	filter := GoroutineFilter{States: []string{"chan receive"}, MinWait: 4 * time.Minute}
+	var ids []int64
+	for _, g := range goroutines {
+		if filter.matches(g) {
+			ids = append(ids, g.ID)
+		}
+	}
+	assert.Equal(t, []int64{8}, ids)
+
+	filter = GoroutineFilter{Packages: []string{"syscall"}}
+	assert.False(t, filter.matches(goroutines[0]))
+	assert.True(t, filter.matches(goroutines[3]))
+}
+
+func blockedReceiver(ch chan struct{}) {
+	<-ch
+}
+
+func TestDumpGoroutines(t *testing.T) {
+	ch := make(chan struct{})
+	defer close(ch)
+	for i := 0; i < 3; i++ {
+		go blockedReceiver(ch)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	var buffer bytes.Buffer
+	logger := New("dump")
+	logger.Out = &buffer
+	logger.Formatter = &TextFormatter{DisableColors: true}
+	logger.SetLevel(ErrorLevel)
+	logger.DumpGoroutines(GoroutineFilter{
+		States:   []string{"chan receive"},
+		Packages: []string{zlogPackage},
+	})
+
+	out := buffer.String()
+	assert.Contains(t, out, "goroutine dump: 1 groups")
+	assert.Contains(t, out, "3 x [chan receive]")
+	assert.Contains(t, out, "github.com/ssor/zlog.blockedReceiver")
+	assert.Equal(t, 1, strings.Count(out, "created_by"))
+}
+
+func newGoroutineLogger() (*Logger, *[]*Entry) {
+	var mu sync.Mutex
+	var entries []*Entry
+	logger := New("goroutine")
+	logger.Formatter = formatterFunc(func(input FormatterInput) {
+		mu.Lock()
+		entries = append(entries, input.(*Entry))
+		mu.Unlock()
+	})
+	return logger, &entries
+}
+
+func TestReportGoroutine(t *testing.T) {
+	logger, entries := newGoroutineLogger()
+	logger.Info("off")
+	logger.ReportGoroutine = true
+	logger.Info("on")
+
+	assert.Zero(t, (*entries)[0].Goroutine)
+	assert.Equal(t, currentGoroutineID(), (*entries)[1].Goroutine)
+}
+
+func TestWithContextLabels(t *testing.T) {
+	logger, entries := newGoroutineLogger()
+	pprof.Do(context.Background(), pprof.Labels("worker", "3", "job", "sync"), func(ctx context.Context) {
+		logger.WithContext(ctx).WithField("n", 1).Info("labeled")
+	})
+	logger.WithContext(context.Background()).Info("unlabeled")
+
+	assert.Equal(t, map[string]string{"worker": "3", "job": "sync"}, (*entries)[0].Labels)
+	assert.Equal(t, 1, (*entries)[0].Data["n"])
+	assert.Nil(t, (*entries)[1].Labels)
+}
+
+func TestOnlyGoroutine(t *testing.T) {
+	logger, entries := newGoroutineLogger()
+	logger.OnlyGoroutine = currentGoroutineID()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		logger.Info("other")
+	}()
+	wg.Wait()
+	logger.Info("mine")
+
+	if assert.Len(t, *entries, 1) {
+		assert.Equal(t, "mine", (*entries)[0].Message)
+	}
+}
+
+func TestGoroutineRendering(t *testing.T) {
+	entry := &Entry{
+		Logger:    New("goroutine"),
+		Data:      Fields{moduleKey: "goroutine"},
+		Message:   "tagged",
+		Goroutine: 17,
+		Labels:    map[string]string{"worker": "3", "job": "sync"},
+	}
+
+	b, err := (&TextFormatter{DisableColors: true}).Format(entry)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "] [g17 job=sync worker=3]")
+
+	b, err = (&TextFormatter{ForceColors: true}).Format(entry)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), fmt.Sprintf(" \x1b[%sm[g17 job=sync worker=3]\x1b[0m", goroutineColor(17)))
+	assert.Equal(t, goroutineColor(17), goroutineColor(17+int64(len(goroutineColors))))
+
+	b, err = (&SeverityFormatter{}).Format(entry)
+	assert.NoError(t, err)
+	var fields struct {
+		Goroutine int64             `json:"goroutine"`
+		Labels    map[string]string `json:"labels"`
+	}
+	assert.NoError(t, json.Unmarshal(b, &fields))
+	assert.Equal(t, int64(17), fields.Goroutine)
+	assert.Equal(t, entry.Labels, fields.Labels)
+}
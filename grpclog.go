@@ -0,0 +1,110 @@
+package zlog
+
+import (
+	"fmt"
+	"os"
+)
+
+// GRPCLogger implements grpclog.LoggerV2 and grpclog.DepthLoggerV2, install
+// it with grpclog.SetLoggerV2(zlog.NewGRPCLogger(logger)). As the interface
+// requires, the Fatal methods exit the program after logging.
+//
+// Verbosity 0 is enabled when the logger logs at Info, any higher verbosity
+// when it logs at Debug.
+type GRPCLogger struct {
+	entry *Entry
+	// os.Exit, replaced by tests.
+	exit func(code int)
+}
+
+func NewGRPCLogger(logger *Logger) *GRPCLogger {
+	return &GRPCLogger{entry: NewEntry(logger, logger.moduleName), exit: os.Exit}
+}
+
+// gRPC wraps its loggers once more in its component loggers, the caller is
+// found with the depth the Depth methods are given.
+func (g *GRPCLogger) log(depth int, level Level, msg string) {
+	if g.entry.Logger.Level < level {
+		return
+	}
+	entry := *g.entry
+	if entry.Logger.ReportCaller {
+		entry.Caller = entry.Logger.getCallerSkip(depth)
+	}
+	entry.log(level, msg)
+}
+
+func (g *GRPCLogger) Info(args ...interface{}) {
+	g.log(0, InfoLevel, fmt.Sprint(args...))
+}
+
+func (g *GRPCLogger) Infoln(args ...interface{}) {
+	g.log(0, InfoLevel, g.entry.sprintlnn(args...))
+}
+
+func (g *GRPCLogger) Infof(format string, args ...interface{}) {
+	g.log(0, InfoLevel, fmt.Sprintf(format, args...))
+}
+
+func (g *GRPCLogger) InfoDepth(depth int, args ...interface{}) {
+	g.log(depth, InfoLevel, fmt.Sprint(args...))
+}
+
+func (g *GRPCLogger) Warning(args ...interface{}) {
+	g.log(0, WarnLevel, fmt.Sprint(args...))
+}
+
+func (g *GRPCLogger) Warningln(args ...interface{}) {
+	g.log(0, WarnLevel, g.entry.sprintlnn(args...))
+}
+
+func (g *GRPCLogger) Warningf(format string, args ...interface{}) {
+	g.log(0, WarnLevel, fmt.Sprintf(format, args...))
+}
+
+func (g *GRPCLogger) WarningDepth(depth int, args ...interface{}) {
+	g.log(depth, WarnLevel, fmt.Sprint(args...))
+}
+
+func (g *GRPCLogger) Error(args ...interface{}) {
+	g.log(0, ErrorLevel, fmt.Sprint(args...))
+}
+
+func (g *GRPCLogger) Errorln(args ...interface{}) {
+	g.log(0, ErrorLevel, g.entry.sprintlnn(args...))
+}
+
+func (g *GRPCLogger) Errorf(format string, args ...interface{}) {
+	g.log(0, ErrorLevel, fmt.Sprintf(format, args...))
+}
+
+func (g *GRPCLogger) ErrorDepth(depth int, args ...interface{}) {
+	g.log(depth, ErrorLevel, fmt.Sprint(args...))
+}
+
+func (g *GRPCLogger) Fatal(args ...interface{}) {
+	g.log(0, FatalLevel, fmt.Sprint(args...))
+	g.exit(1)
+}
+
+func (g *GRPCLogger) Fatalln(args ...interface{}) {
+	g.log(0, FatalLevel, g.entry.sprintlnn(args...))
+	g.exit(1)
+}
+
+func (g *GRPCLogger) Fatalf(format string, args ...interface{}) {
+	g.log(0, FatalLevel, fmt.Sprintf(format, args...))
+	g.exit(1)
+}
+
+func (g *GRPCLogger) FatalDepth(depth int, args ...interface{}) {
+	g.log(depth, FatalLevel, fmt.Sprint(args...))
+	g.exit(1)
+}
+
+func (g *GRPCLogger) V(l int) bool {
+	if l > 0 {
+		return g.entry.Logger.Level >= DebugLevel
+	}
+	return g.entry.Logger.Level >= InfoLevel
+}
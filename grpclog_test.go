@@ -0,0 +1,84 @@
+package zlog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// grpcLoggerV2 and grpcDepthLoggerV2 copy the interfaces of
+// google.golang.org/grpc/grpclog, which is too big a dependency for tests.
+type grpcLoggerV2 interface {
+	Info(args ...interface{})
+	Infoln(args ...interface{})
+	Infof(format string, args ...interface{})
+	Warning(args ...interface{})
+	Warningln(args ...interface{})
+	Warningf(format string, args ...interface{})
+	Error(args ...interface{})
+	Errorln(args ...interface{})
+	Errorf(format string, args ...interface{})
+	Fatal(args ...interface{})
+	Fatalln(args ...interface{})
+	Fatalf(format string, args ...interface{})
+	V(l int) bool
+}
+
+type grpcDepthLoggerV2 interface {
+	grpcLoggerV2
+	InfoDepth(depth int, args ...interface{})
+	WarningDepth(depth int, args ...interface{})
+	ErrorDepth(depth int, args ...interface{})
+	FatalDepth(depth int, args ...interface{})
+}
+
+var _ grpcDepthLoggerV2 = &GRPCLogger{}
+
+// grpcComponentInfo mimics grpclog.InfoDepth, which adds its own frame to
+// the depth.
+func grpcComponentInfo(l grpcDepthLoggerV2, args ...interface{}) {
+	l.InfoDepth(1, args...)
+}
+
+func TestGRPCLogger(t *testing.T) {
+	logger, entries := newEntryChannelLogger("grpc", 4)
+	logger.SetLevel(WarnLevel)
+
+	g := NewGRPCLogger(logger)
+	var l grpcLoggerV2 = g
+	l.Infof("hidden %d", 1)
+	l.Warningln("transport", "closing")
+	l.Errorf("dial %s", "failed")
+	assert.False(t, l.V(0))
+
+	exited := 0
+	g.exit = func(code int) { exited = code }
+	l.Fatal("fatal")
+	assert.Equal(t, 1, exited)
+	close(entries)
+
+	var got []loggedLine
+	for entry := range entries {
+		assert.Equal(t, "grpc", entry.data[moduleKey])
+		got = append(got, loggedLine{entry.level, entry.msg})
+	}
+	assert.Equal(t, []loggedLine{
+		{WarnLevel, "transport closing"},
+		{ErrorLevel, "dial failed"},
+		{FatalLevel, "fatal"},
+	}, got)
+}
+
+func TestGRPCLoggerDepth(t *testing.T) {
+	var input FormatterInput
+	logger := New("grpc")
+	logger.ReportCaller = true
+	logger.Formatter = formatterFunc(func(entry FormatterInput) {
+		input = entry
+	})
+
+	line := currentLine() + 1
+	grpcComponentInfo(NewGRPCLogger(logger), "depth")
+	assert.Equal(t, line, input.GetCaller().Line)
+	assert.True(t, NewGRPCLogger(logger).V(2))
+}
@@ -0,0 +1,44 @@
+package zlog
+
+import (
+	"runtime"
+	"strings"
+)
+
+// The tests log from package zlog, their own frames are reported as callers.
+func init() {
+	isZlogFrame := isInternalFrame
+	isInternalFrame = func(frame runtime.Frame) bool {
+		return isZlogFrame(frame) && !strings.HasSuffix(frame.File, "_test.go")
+	}
+}
+
+func currentLine() int {
+	_, _, line, _ := runtime.Caller(1)
+	return line
+}
+
+// formatterFunc hands the entries to a function instead of formatting them.
+type formatterFunc func(entry FormatterInput)
+
+func (f formatterFunc) Format(entry FormatterInput) ([]byte, error) {
+	f(entry)
+	return nil, nil
+}
+
+type loggedEntry struct {
+	level Level
+	msg   string
+	data  Fields
+}
+
+// newEntryChannelLogger returns a logger of module sending the entries it
+// logs to the returned channel, for the entries logged by other goroutines.
+func newEntryChannelLogger(module string, size int) (*Logger, chan loggedEntry) {
+	entries := make(chan loggedEntry, size)
+	logger := New(module)
+	logger.Formatter = formatterFunc(func(entry FormatterInput) {
+		entries <- loggedEntry{entry.GetLevel(), entry.GetMessage(), entry.GetData()}
+	})
+	return logger, entries
+}
@@ -0,0 +1,191 @@
+package zlog
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const defaultRequestIDHeader = "X-Request-ID"
+
+// HTTPOptions configures HTTPMiddleware.
+type HTTPOptions struct {
+	// Header the request ID is read from, and set on the response. Requests
+	// without one get a generated ID. Defaults to "X-Request-ID".
+	RequestIDHeader string
+
+	// Paths which are requested so often, like health checks, that only one
+	// of every SampleEvery requests is logged. Failed requests are always
+	// logged.
+	SampledPaths []string
+	SampleEvery  int
+}
+
+type entryContextKey struct{}
+
+// ContextWithEntry returns a context carrying entry, see EntryFromContext.
+func ContextWithEntry(ctx context.Context, entry *Entry) context.Context {
+	return context.WithValue(ctx, entryContextKey{}, entry)
+}
+
+// EntryFromContext returns the entry put in the context by HTTPMiddleware or
+// ContextWithEntry, so that a handler logs with the ID of its request. It
+// falls back to the standard logger.
+func EntryFromContext(ctx context.Context) *Entry {
+	if entry, ok := ctx.Value(entryContextKey{}).(*Entry); ok {
+		return entry
+	}
+	return StandardLogger().WithFields(Fields{})
+}
+
+// HTTPMiddleware logs an access log entry for every request handled by the
+// wrapped handler, with the method, path, status, size of the response,
+// latency, remote address and request ID. Requests failing with a 5xx
+// status are logged at Error, with a 4xx status at Warn. A panicking handler
+// is logged with its stack trace and answered with a 500.
+//
+//	http.ListenAndServe(":8080", zlog.HTTPMiddleware(logger, zlog.HTTPOptions{})(mux))
+func HTTPMiddleware(logger *Logger, opts HTTPOptions) func(http.Handler) http.Handler {
+	header := opts.RequestIDHeader
+	if header == "" {
+		header = defaultRequestIDHeader
+	}
+	sampled := make(map[string]*uint64, len(opts.SampledPaths))
+	for _, path := range opts.SampledPaths {
+		sampled[path] = new(uint64)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			requestID := r.Header.Get(header)
+			if requestID == "" {
+				requestID = newRequestID()
+			}
+			w.Header().Set(header, requestID)
+
+			entry := logger.WithFields(Fields{
+				"request_id": requestID,
+				"method":     r.Method,
+				"path":       r.URL.Path,
+			})
+			r = r.WithContext(ContextWithEntry(r.Context(), entry))
+			rw := &responseWriter{ResponseWriter: w}
+
+			defer func() {
+				fields := Fields{
+					"remote":  r.RemoteAddr,
+					"latency": time.Since(start).String(),
+				}
+				panicked := false
+				if p := recover(); p != nil {
+					panicked = true
+					if p == http.ErrAbortHandler {
+						panic(p)
+					}
+					if !rw.wroteHeader {
+						http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					}
+					fields["panic"] = fmt.Sprint(p)
+					fields[StackTraceKey] = captureStackTrace(0, logger.StackTraceFilter)
+				}
+				fields["status"] = rw.status()
+				fields["bytes"] = rw.bytes
+
+				level := statusLevel(rw.status())
+				if panicked {
+					// The status can be a success when the handler wrote
+					// it before panicking.
+					level = ErrorLevel
+				} else if level == InfoLevel && skipSample(sampled[r.URL.Path], opts.SampleEvery) {
+					return
+				}
+				entry.WithFields(fields).logLevel(level, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// skipSample tells whether a request of a sampled path is left out, the
+// first request of every SampleEvery is logged.
+func skipSample(counter *uint64, every int) bool {
+	if counter == nil || every <= 1 {
+		return false
+	}
+	return (atomic.AddUint64(counter, 1)-1)%uint64(every) != 0
+}
+
+func statusLevel(status int) Level {
+	switch {
+	case status >= 500:
+		return ErrorLevel
+	case status >= 400:
+		return WarnLevel
+	default:
+		return InfoLevel
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// responseWriter records the status and size of the response.
+type responseWriter struct {
+	http.ResponseWriter
+	code        int
+	bytes       int
+	wroteHeader bool
+}
+
+func (w *responseWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.code = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("zlog: the ResponseWriter does not support hijacking")
+}
+
+// Unwrap lets http.ResponseController reach the original writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,120 @@
+package zlog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func serve(handler http.Handler, method, path string, header http.Header) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHTTPMiddlewareAccessLog(t *testing.T) {
+	logger, entries := newEntryChannelLogger("http", 2)
+	handler := HTTPMiddleware(logger, HTTPOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		EntryFromContext(r.Context()).Info("inside")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("not here"))
+	}))
+
+	rec := serve(handler, "GET", "/users/1", http.Header{"X-Request-Id": {"abc"}})
+	assert.Equal(t, "abc", rec.Header().Get("X-Request-ID"))
+	close(entries)
+
+	inside := <-entries
+	assert.Equal(t, "inside", inside.msg)
+	assert.Equal(t, "abc", inside.data["request_id"])
+
+	access := <-entries
+	assert.Equal(t, WarnLevel, access.level)
+	assert.Equal(t, "GET /users/1", access.msg)
+	assert.Equal(t, "abc", access.data["request_id"])
+	assert.Equal(t, "GET", access.data["method"])
+	assert.Equal(t, "/users/1", access.data["path"])
+	assert.Equal(t, 404, access.data["status"])
+	assert.Equal(t, 8, access.data["bytes"])
+	assert.Equal(t, "192.0.2.1:1234", access.data["remote"])
+	assert.Contains(t, access.data, "latency")
+}
+
+func TestHTTPMiddlewareGeneratesRequestID(t *testing.T) {
+	logger, entries := newEntryChannelLogger("http", 1)
+	handler := HTTPMiddleware(logger, HTTPOptions{RequestIDHeader: "X-Trace"})(http.NotFoundHandler())
+
+	rec := serve(handler, "GET", "/", nil)
+	id := rec.Header().Get("X-Trace")
+	assert.Len(t, id, 16)
+	assert.Equal(t, id, (<-entries).data["request_id"])
+}
+
+func TestHTTPMiddlewareRecoversPanics(t *testing.T) {
+	logger, entries := newEntryChannelLogger("http", 1)
+	handler := HTTPMiddleware(logger, HTTPOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	rec := serve(handler, "POST", "/crash", nil)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	entry := <-entries
+	assert.Equal(t, ErrorLevel, entry.level)
+	assert.Equal(t, "boom", entry.data["panic"])
+	assert.Equal(t, 500, entry.data["status"])
+	st := entry.data[StackTraceKey].(StackTrace)
+	found := false
+	for _, frame := range st {
+		found = found || strings.Contains(frame.Function, "TestHTTPMiddlewareRecoversPanics")
+	}
+	assert.True(t, found, st.String())
+}
+
+func TestHTTPMiddlewarePanicAfterWriteHeader(t *testing.T) {
+	logger, entries := newEntryChannelLogger("http", 2)
+	handler := HTTPMiddleware(logger, HTTPOptions{SampledPaths: []string{"/late"}, SampleEvery: 100})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		panic("late")
+	}))
+
+	serve(handler, "GET", "/late", nil)
+	serve(handler, "GET", "/late", nil)
+
+	for i := 0; i < 2; i++ {
+		entry := <-entries
+		assert.Equal(t, ErrorLevel, entry.level)
+		assert.Equal(t, 200, entry.data["status"])
+		assert.NotNil(t, entry.data[StackTraceKey])
+	}
+}
+
+func TestHTTPMiddlewareSampling(t *testing.T) {
+	logger, entries := newEntryChannelLogger("http", 10)
+	handler := HTTPMiddleware(logger, HTTPOptions{SampledPaths: []string{"/healthz"}, SampleEvery: 3})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+
+	for i := 0; i < 7; i++ {
+		serve(handler, "GET", "/healthz", nil)
+	}
+	serve(handler, "GET", "/healthz?fail=1", nil)
+	serve(handler, "GET", "/other", nil)
+	close(entries)
+
+	var statuses []interface{}
+	for entry := range entries {
+		statuses = append(statuses, entry.data["status"])
+	}
+	// Requests 1, 4 and 7 of /healthz, the failed one and /other.
+	assert.Equal(t, []interface{}{200, 200, 200, 503, 200}, statuses)
+}
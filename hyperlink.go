@@ -0,0 +1,62 @@
+package zlog
+
+import (
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// hyperlink wraps text in an OSC 8 escape sequence pointing at file:line, as
+// described by HyperlinkTemplate.
+func (f *TextFormatter) hyperlink(file string, line int, text string) string {
+	relFile := file
+	if f.HyperlinkRoot != "" {
+		if rel, err := filepath.Rel(f.HyperlinkRoot, file); err == nil && !strings.HasPrefix(rel, "..") {
+			relFile = filepath.ToSlash(rel)
+		}
+	}
+
+	values := []struct{ placeholder, value string }{
+		{"{file}", escapePath(file)},
+		{"{relfile}", escapePath(relFile)},
+		{"{line}", strconv.Itoa(line)},
+		{"{commit}", escapePath(f.HyperlinkCommit)},
+	}
+	var target strings.Builder
+	template := f.HyperlinkTemplate
+	for template != "" {
+		i := strings.IndexByte(template, '{')
+		if i < 0 {
+			target.WriteString(template)
+			break
+		}
+		target.WriteString(template[:i])
+		template = template[i:]
+		matched := false
+		for _, v := range values {
+			if !strings.HasPrefix(template, v.placeholder) {
+				continue
+			}
+			value := v.value
+			// Absolute paths after a slash, like "vscode://file/{file}", do
+			// not double it.
+			if v.placeholder != "{line}" && strings.HasSuffix(target.String(), "/") {
+				value = strings.TrimPrefix(value, "/")
+			}
+			target.WriteString(value)
+			template = template[len(v.placeholder):]
+			matched = true
+			break
+		}
+		if !matched {
+			target.WriteByte('{')
+			template = template[1:]
+		}
+	}
+	return "\x1b]8;;" + target.String() + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+func escapePath(path string) string {
+	return (&url.URL{Path: path}).EscapedPath()
+}
@@ -0,0 +1,49 @@
+package zlog
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHyperlinkTemplates(t *testing.T) {
+	f := &TextFormatter{HyperlinkTemplate: "vscode://file/{file}:{line}"}
+	assert.Equal(t,
+		"\x1b]8;;vscode://file/src/app/main.go:12\x1b\\main.go:12\x1b]8;;\x1b\\",
+		f.hyperlink("/src/app/main.go", 12, "main.go:12"))
+
+	f = &TextFormatter{
+		HyperlinkTemplate: "https://example.com/app/blob/{commit}/{relfile}#L{line}",
+		HyperlinkRoot:     "/src/app",
+		HyperlinkCommit:   "0123abc",
+	}
+	assert.Equal(t,
+		"\x1b]8;;https://example.com/app/blob/0123abc/cmd/my%20tool/main.go#L7\x1b\\x\x1b]8;;\x1b\\",
+		f.hyperlink("/src/app/cmd/my tool/main.go", 7, "x"))
+
+	// Files outside the root keep their full path.
+	assert.Contains(t, f.hyperlink("/other/main.go", 7, "x"), "/0123abc/other/main.go#L7")
+
+	f.HyperlinkCommit = "release/1.0 rc"
+	assert.Contains(t, f.hyperlink("/src/app/main.go", 7, "x"), "/blob/release/1.0%20rc/main.go#L7")
+
+	f = &TextFormatter{HyperlinkTemplate: "idea://open?file={file}&line={line}"}
+	assert.Contains(t, f.hyperlink("/src/app/main.go", 7, "x"), "idea://open?file=/src/app/main.go&line=7")
+}
+
+func TestHyperlinkOnlyWhenColored(t *testing.T) {
+	tf := &TextFormatter{HyperlinkTemplate: "idea://open?file={file}&line={line}"}
+
+	entry := WithField("a", 1)
+	entry.Caller = &runtime.Frame{File: "/src/app/main.go", Line: 12}
+
+	tf.DisableColors = true
+	b, _ := tf.Format(entry)
+	assert.NotContains(t, string(b), "\x1b]8;;")
+
+	tf.DisableColors = false
+	tf.ForceColors = true
+	b, _ = tf.Format(entry)
+	assert.Contains(t, string(b), "\x1b]8;;idea://open?file=/src/app/main.go&line=12")
+}
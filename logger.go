@@ -1,17 +1,17 @@
 package zlog
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"regexp"
+	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
-var loggerDefaultCallDepth = 6
-
 type Logger struct {
 	// The logs are `io.Copy`'d to this in a mutex. It's common to set this to a
 	// file, or leave it default which is `os.Stderr`. You can also set this to
@@ -32,8 +32,46 @@ type Logger struct {
 	mu MutexWrap
 	// Reusable empty entry
 	entryPool sync.Pool
+	// Record the file, line and function which logged each entry in
+	// `Entry.Caller`, so that formatters can print it.
+	ReportCaller bool
+	// Frames skipped above the first caller outside zlog, see AddCallerSkip.
+	callerSkip int
+	// Capture the stack trace of entries logged at StackTraceLevel or a more
+	// severe level, it is stored in the `StackTraceKey` field.
+	ReportStackTrace bool
+	StackTraceLevel  Level
+	// Frames left out of the captured stack traces.
+	StackTraceFilter StackFilter
+	// Mask or leave out the sensitive values of entries, in their fields,
+	// labels, JSON raw and message, before the formatter sees them. The first rule
+	// matching the key of a field applies, the values found by the rules are
+	// all replaced.
+	RedactRules []RedactRule
+	// Record the ID of the goroutine logging each entry in `Entry.Goroutine`,
+	// so that the lines of concurrent goroutines can be told apart.
+	ReportGoroutine bool
+	// Only log the entries of the goroutine with this ID, when not zero.
+	OnlyGoroutine int64
+	// How deep WithStruct, WithTable and WithDiff go into nested values,
+	// deeper ones are logged as "<max depth>". 10 when not set.
+	StructMaxDepth int
+	// Also show the unexported fields of the values given to WithStruct,
+	// WithTable and WithDiff. They are read with package unsafe, and their
+	// String and Error methods are called.
+	StructUnexported bool
 
 	moduleName string
+	// The logger AddCallerSkip was called on, whose lock and watch points are
+	// shared, and the copies it returned, which follow its SetOutput and
+	// SetLevel.
+	parent *Logger
+	copies []*Logger
+	// The watch points of Watch, by name.
+	watchesMu sync.Mutex
+	watches   map[string]*Watcher
+	// *outputTerminal describing Out, see outputTerminal.
+	terminal atomic.Value
 }
 
 type MutexWrap struct {
@@ -80,7 +118,9 @@ func New(moduleNames ...string) *Logger {
 		Level:      DebugLevel,
 		moduleName: strings.Join(moduleNames, "/"),
 	}
+	loggersMu.Lock()
 	loggers = append(loggers, logger)
+	loggersMu.Unlock()
 	return logger
 }
 
@@ -88,15 +128,97 @@ func (logger *Logger) Name() string {
 	return logger.moduleName
 }
 
+// root returns the logger whose lock and watch points are used, see
+// AddCallerSkip.
+func (logger *Logger) root() *Logger {
+	if logger.parent != nil {
+		return logger.parent
+	}
+	return logger
+}
+
+// family returns a root logger and the copies of AddCallerSkip sharing its
+// state, with its lock held.
+func (logger *Logger) family() []*Logger {
+	return append([]*Logger{logger}, logger.copies...)
+}
+
 // SetOutput sets the standard logger output.
 func (logger *Logger) SetOutput(out io.Writer) {
-	logger.mu.Lock()
-	defer logger.mu.Unlock()
-	logger.Out = out
+	root := logger.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	for _, l := range root.family() {
+		l.Out = out
+	}
 }
 
 func (logger *Logger) SetLevel(level Level) {
-	logger.Level = level
+	root := logger.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	for _, l := range root.family() {
+		l.Level = level
+	}
+}
+
+// GetLevel returns the level set by SetLevel.
+func (logger *Logger) GetLevel() Level {
+	root := logger.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	return logger.Level
+}
+
+// AddCallerSkip returns a copy of the logger whose reported caller skips n
+// more frames above the first one outside zlog. Use it when the logger is
+// wrapped by helper functions, so that the code calling the helpers is
+// reported instead. The logger itself is left as it is.
+//
+// The copy writes under the lock of the logger and shares its watch points.
+// It follows the SetOutput and SetLevel of the logger, and the logger follows
+// the ones of the copy.
+func (logger *Logger) AddCallerSkip(n int) *Logger {
+	root := logger.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	skipped := &Logger{
+		Out:              logger.Out,
+		Formatter:        logger.Formatter,
+		Level:            logger.Level,
+		ReportCaller:     logger.ReportCaller,
+		callerSkip:       logger.callerSkip + n,
+		ReportStackTrace: logger.ReportStackTrace,
+		StackTraceLevel:  logger.StackTraceLevel,
+		StackTraceFilter: logger.StackTraceFilter,
+		RedactRules:      logger.RedactRules,
+		ReportGoroutine:  logger.ReportGoroutine,
+		OnlyGoroutine:    logger.OnlyGoroutine,
+		StructMaxDepth:   logger.StructMaxDepth,
+		StructUnexported: logger.StructUnexported,
+		moduleName:       logger.moduleName,
+		parent:           root,
+	}
+	root.copies = append(root.copies, skipped)
+
+	loggersMu.Lock()
+	loggers = append(loggers, skipped)
+	loggersMu.Unlock()
+	return skipped
+}
+
+func (logger *Logger) getCaller() *runtime.Frame {
+	return logger.getCallerSkip(0)
+}
+
+// getCallerSkip also skips the frames of adapters between the code logging
+// and zlog, by count or by package.
+func (logger *Logger) getCallerSkip(skip int, outside ...string) *runtime.Frame {
+	frame, ok := externalCaller(logger.callerSkip+skip, outside...)
+	if !ok {
+		return nil
+	}
+	return &frame
 }
 
 func (logger *Logger) newEntry() *Entry {
@@ -111,12 +233,33 @@ func (logger *Logger) releaseEntry(entry *Entry) {
 	logger.entryPool.Put(entry)
 }
 
+// WithStruct logs value as JSON raw, with the fields configured by their
+// `zlog` tags. See StructMaxDepth and StructUnexported.
 func (logger *Logger) WithStruct(value interface{}) *Entry {
-	bs, err := json.Marshal(value)
-	if err != nil {
-		return logger.WithError(err)
-	}
-	return logger.WithJsonRaw(bs)
+	return logger.WithJsonRaw(encodeStruct(value, logger.structOptions()))
+}
+
+// WithDiff logs what changed from before to after, maps, structs, slices
+// or JSON raws, so that what a function mutated can be seen at a glance.
+func (logger *Logger) WithDiff(key string, before, after interface{}) *Entry {
+	entry := logger.newEntry()
+	defer logger.releaseEntry(entry)
+	return entry.WithDiff(key, before, after)
+}
+
+// WithTable logs rows, a slice of structs or maps, as a table of the given
+// columns, or of all their fields.
+func (logger *Logger) WithTable(key string, rows interface{}, columns ...string) *Entry {
+	entry := logger.newEntry()
+	defer logger.releaseEntry(entry)
+	return entry.WithTable(key, rows, columns...)
+}
+
+// WithContext creates an entry with the runtime/pprof labels of ctx.
+func (logger *Logger) WithContext(ctx context.Context) *Entry {
+	entry := logger.newEntry()
+	defer logger.releaseEntry(entry)
+	return entry.WithContext(ctx)
 }
 
 func (logger *Logger) WithJsonRaw(bs []byte) *Entry {
@@ -178,54 +321,115 @@ func (logger *Logger) WithError(err error) *Entry {
 	return entry.WithError(err)
 }
 
+// A LevelParser finds the level of a line written by a component which does
+// not use zlog, from a prefix like `[WARN]`. See `Logger.WriterWithParser`.
 type LevelParser interface {
 	Parse(*string) (Level, error)
 }
 
+// A LevelStripper is a LevelParser which also returns the line without the
+// prefix it found the level in. Writers strip the prefixes of the other
+// parsers as if it was the bracketed word of RegexpParser.
+type LevelStripper interface {
+	LevelParser
+	ParseAndStrip(s string) (Level, string, error)
+}
+
+var levelPrefix = regexp.MustCompile(`^\[\w+\]`)
+
+// RegexpParser understands any level name `ParseLevel` does, e.g. `[warning]`.
 type RegexpParser struct {
 	r *regexp.Regexp
 }
 
+func NewRegexpParser() *RegexpParser {
+	pr := &RegexpParser{}
+	pr.prefixRegex()
+	return pr
+}
+
 func (pr *RegexpParser) prefixRegex() {
 	//pr.r = regexp.MustCompile(`^\\[(?P<Level?\\w+)\\]`)
-	pr.r = regexp.MustCompile(`^\[\w+\]`)
+	pr.r = levelPrefix
 }
 
 func (pr *RegexpParser) Parse(s *string) (Level, error) {
-	b := pr.r.Find([]byte(*s))
-	return ParseLevel(string(b)[1 : len(b)-1])
+	level, _, err := pr.ParseAndStrip(*s)
+	return level, err
+}
+
+func (pr *RegexpParser) ParseAndStrip(s string) (Level, string, error) {
+	// The zero value is usable, and shared between goroutines: pr is never
+	// written here.
+	r := pr.r
+	if r == nil {
+		r = levelPrefix
+	}
+	loc := r.FindStringIndex(s)
+	if loc == nil {
+		return DebugLevel, s, fmt.Errorf("no level prefix in %q", tripHeadAndTail(s, 32))
+	}
+	level, err := ParseLevel(s[loc[0]+1 : loc[1]-1])
+	return level, strings.TrimLeft(s[loc[1]:], " "), err
 }
 
+// PrefixStrCmp only knows the upper case level names, but is faster than
+// RegexpParser.
 type PrefixStrCmp struct{}
 
 func (p *PrefixStrCmp) Parse(s *string) (Level, error) {
-	str := *s
+	level, _, err := p.ParseAndStrip(*s)
+	return level, err
+}
+
+func (p *PrefixStrCmp) ParseAndStrip(s string) (Level, string, error) {
+	str := s
+	if len(str) < 7 {
+		str += "       "
+	}
 	prefix := str[:7]
 
+	var level Level
 	switch prefix {
 	case "[INFO] ":
-		return InfoLevel, nil
+		level = InfoLevel
 	case "[WARN] ":
-		return WarnLevel, nil
+		level = WarnLevel
 	case "[ERROR]":
-		return ErrorLevel, nil
+		level = ErrorLevel
 	case "[FATAL]":
-		return FatalLevel, nil
+		level = FatalLevel
 	case "[DEBUG]":
-		return DebugLevel, nil
+		level = DebugLevel
 	case "[PANIC]":
-		return PanicLevel, nil
+		level = PanicLevel
 	default:
-		return DebugLevel, nil
+		return DebugLevel, s, fmt.Errorf("no level prefix in %q", tripHeadAndTail(s, 32))
+	}
+	return level, strings.TrimLeft(s[len(strings.TrimRight(prefix, " ")):], " "), nil
+}
+
+// parseAndStrip returns the level parser finds in s, and s without the
+// prefix holding it.
+func parseAndStrip(parser LevelParser, s string) (Level, string, error) {
+	if stripper, ok := parser.(LevelStripper); ok {
+		return stripper.ParseAndStrip(s)
+	}
+	level, err := parser.Parse(&s)
+	if err != nil {
+		return level, s, err
+	}
+	if loc := levelPrefix.FindStringIndex(s); loc != nil {
+		s = strings.TrimLeft(s[loc[1]:], " ")
 	}
-	return WarnLevel, fmt.Errorf("prefixstrcmp switch failed?")
+	return level, s, nil
 }
 
 func (logger *Logger) Debugf(format string, args ...interface{}) {
 	if logger.Level >= DebugLevel {
 		entry := logger.newEntry()
 		//entry.Debugf(format, args...)
-		entry.log(0, DebugLevel, fmt.Sprintf(format, args...))
+		entry.log(DebugLevel, fmt.Sprintf(format, args...))
 		logger.releaseEntry(entry)
 	}
 }
@@ -233,35 +437,44 @@ func (logger *Logger) Debugf(format string, args ...interface{}) {
 func (logger *Logger) Infof(format string, args ...interface{}) {
 	if logger.Level >= InfoLevel {
 		entry := logger.newEntry()
-		entry.log(0, InfoLevel, fmt.Sprintf(format, args...))
+		entry.log(InfoLevel, fmt.Sprintf(format, args...))
 		logger.releaseEntry(entry)
 	}
 }
 
 func (logger *Logger) Printf(format string, args ...interface{}) {
 	entry := logger.newEntry()
-	entry.log(0, logger.Level, fmt.Sprintf(format, args...))
+	entry.log(logger.Level, fmt.Sprintf(format, args...))
 	logger.releaseEntry(entry)
 }
 
+func (logger *Logger) logLevel(level Level, msg string) {
+	if logger.Level >= level {
+		entry := logger.newEntry()
+		entry.logLevel(level, msg)
+		logger.releaseEntry(entry)
+	}
+}
+
 func (logger *Logger) Highlightf(format string, args ...interface{}) {
-	logger.highlight(loggerDefaultCallDepth, fmt.Sprintf(format, args...))
+	logger.highlight(fmt.Sprintf(format, args...))
 }
 
 func (logger *Logger) Highlight(args ...interface{}) {
-	logger.highlight(loggerDefaultCallDepth, args...)
+	logger.highlight(args...)
 }
 
-func (logger *Logger) highlight(callDepth int, args ...interface{}) {
+// Highlighted lines always show where they come from.
+func (logger *Logger) highlight(args ...interface{}) {
 	entry := logger.newEntry()
-	entry.log(callDepth, ErrorLevel, fmt.Sprint(args...))
+	entry.logWithCaller(ErrorLevel, fmt.Sprint(args...))
 	logger.releaseEntry(entry)
 }
 
 func (logger *Logger) Warnf(format string, args ...interface{}) {
 	if logger.Level >= WarnLevel {
 		entry := logger.newEntry()
-		entry.log(0, WarnLevel, fmt.Sprintf(format, args...))
+		entry.log(WarnLevel, fmt.Sprintf(format, args...))
 		logger.releaseEntry(entry)
 	}
 }
@@ -269,7 +482,7 @@ func (logger *Logger) Warnf(format string, args ...interface{}) {
 func (logger *Logger) Warningf(format string, args ...interface{}) {
 	if logger.Level >= WarnLevel {
 		entry := logger.newEntry()
-		entry.log(0, WarnLevel, fmt.Sprintf(format, args...))
+		entry.log(WarnLevel, fmt.Sprintf(format, args...))
 		logger.releaseEntry(entry)
 	}
 }
@@ -277,7 +490,7 @@ func (logger *Logger) Warningf(format string, args ...interface{}) {
 func (logger *Logger) Errorf(format string, args ...interface{}) {
 	if logger.Level >= ErrorLevel {
 		entry := logger.newEntry()
-		entry.log(0, ErrorLevel, fmt.Sprintf(format, args...))
+		entry.log(ErrorLevel, fmt.Sprintf(format, args...))
 		logger.releaseEntry(entry)
 	}
 }
@@ -285,7 +498,7 @@ func (logger *Logger) Errorf(format string, args ...interface{}) {
 func (logger *Logger) Fatalf(format string, args ...interface{}) {
 	if logger.Level >= FatalLevel {
 		entry := logger.newEntry()
-		entry.log(0, FatalLevel, fmt.Sprintf(format, args...))
+		entry.log(FatalLevel, fmt.Sprintf(format, args...))
 		logger.releaseEntry(entry)
 	}
 }
@@ -293,7 +506,7 @@ func (logger *Logger) Fatalf(format string, args ...interface{}) {
 func (logger *Logger) Panicf(format string, args ...interface{}) {
 	if logger.Level >= PanicLevel {
 		entry := logger.newEntry()
-		entry.log(0, PanicLevel, fmt.Sprintf(format, args...))
+		entry.log(PanicLevel, fmt.Sprintf(format, args...))
 		logger.releaseEntry(entry)
 	}
 }
@@ -301,66 +514,66 @@ func (logger *Logger) Panicf(format string, args ...interface{}) {
 func (logger *Logger) Debug(args ...interface{}) {
 	if logger.Level >= DebugLevel {
 		entry := logger.newEntry()
-		entry.log(0, DebugLevel, fmt.Sprint(args...))
+		entry.log(DebugLevel, fmt.Sprint(args...))
 		logger.releaseEntry(entry)
 	}
 }
 
 func (logger *Logger) Passf(format string, args ...interface{}) {
 	entry := logger.newEntry()
-	entry.log(0, InfoLevel, fmt.Sprintf("[PASS]"+format, args...))
+	entry.log(InfoLevel, fmt.Sprintf("[PASS]"+format, args...))
 	logger.releaseEntry(entry)
 }
 
 func (logger *Logger) Pass(args ...interface{}) {
 	entry := logger.newEntry()
 	args = append([]interface{}{"[PASS]"}, args...)
-	entry.log(0, InfoLevel, fmt.Sprint(args...))
+	entry.log(InfoLevel, fmt.Sprint(args...))
 	logger.releaseEntry(entry)
 }
 func (logger *Logger) Failedf(format string, args ...interface{}) {
 	entry := logger.newEntry()
-	entry.log(0, ErrorLevel, fmt.Sprintf("[FAIL]"+format, args...))
+	entry.log(ErrorLevel, fmt.Sprintf("[FAIL]"+format, args...))
 	logger.releaseEntry(entry)
 }
 
 func (logger *Logger) Failed(args ...interface{}) {
 	entry := logger.newEntry()
 	args = append([]interface{}{"[FAIL]"}, args...)
-	entry.log(0, ErrorLevel, fmt.Sprint(args...))
+	entry.log(ErrorLevel, fmt.Sprint(args...))
 	logger.releaseEntry(entry)
 }
 func (logger *Logger) Successf(format string, args ...interface{}) {
 	entry := logger.newEntry()
-	entry.log(0, InfoLevel, fmt.Sprintf("[OK]"+format, args...))
+	entry.log(InfoLevel, fmt.Sprintf("[OK]"+format, args...))
 	logger.releaseEntry(entry)
 }
 
 func (logger *Logger) Success(args ...interface{}) {
 	entry := logger.newEntry()
 	args = append([]interface{}{"[OK]"}, args...)
-	entry.log(0, InfoLevel, fmt.Sprint(args...))
+	entry.log(InfoLevel, fmt.Sprint(args...))
 	logger.releaseEntry(entry)
 }
 
 func (logger *Logger) Info(args ...interface{}) {
 	if logger.Level >= InfoLevel {
 		entry := logger.newEntry()
-		entry.log(0, InfoLevel, fmt.Sprint(args...))
+		entry.log(InfoLevel, fmt.Sprint(args...))
 		logger.releaseEntry(entry)
 	}
 }
 
 func (logger *Logger) Print(args ...interface{}) {
 	entry := logger.newEntry()
-	entry.log(0, logger.Level, fmt.Sprint(args...))
+	entry.log(logger.Level, fmt.Sprint(args...))
 	logger.releaseEntry(entry)
 }
 
 func (logger *Logger) Warn(args ...interface{}) {
 	if logger.Level >= WarnLevel {
 		entry := logger.newEntry()
-		entry.log(0, WarnLevel, fmt.Sprint(args...))
+		entry.log(WarnLevel, fmt.Sprint(args...))
 		logger.releaseEntry(entry)
 	}
 }
@@ -368,7 +581,7 @@ func (logger *Logger) Warn(args ...interface{}) {
 func (logger *Logger) Error(args ...interface{}) {
 	if logger.Level >= ErrorLevel {
 		entry := logger.newEntry()
-		entry.log(0, ErrorLevel, fmt.Sprint(args...))
+		entry.log(ErrorLevel, fmt.Sprint(args...))
 		logger.releaseEntry(entry)
 	}
 }
@@ -376,7 +589,7 @@ func (logger *Logger) Error(args ...interface{}) {
 func (logger *Logger) Fatal(args ...interface{}) {
 	if logger.Level >= FatalLevel {
 		entry := logger.newEntry()
-		entry.log(0, FatalLevel, fmt.Sprint(args...))
+		entry.log(FatalLevel, fmt.Sprint(args...))
 		logger.releaseEntry(entry)
 	}
 }
@@ -384,7 +597,7 @@ func (logger *Logger) Fatal(args ...interface{}) {
 func (logger *Logger) Panic(args ...interface{}) {
 	if logger.Level >= PanicLevel {
 		entry := logger.newEntry()
-		entry.log(0, PanicLevel, fmt.Sprint(args...))
+		entry.log(PanicLevel, fmt.Sprint(args...))
 		logger.releaseEntry(entry)
 	}
 }
@@ -392,7 +605,7 @@ func (logger *Logger) Panic(args ...interface{}) {
 func (logger *Logger) Debugln(args ...interface{}) {
 	if logger.Level >= DebugLevel {
 		entry := logger.newEntry()
-		entry.log(0, DebugLevel, fmt.Sprintln(args...))
+		entry.log(DebugLevel, fmt.Sprintln(args...))
 		logger.releaseEntry(entry)
 	}
 }
@@ -400,21 +613,21 @@ func (logger *Logger) Debugln(args ...interface{}) {
 func (logger *Logger) Infoln(args ...interface{}) {
 	if logger.Level >= InfoLevel {
 		entry := logger.newEntry()
-		entry.log(0, InfoLevel, fmt.Sprintln(args...))
+		entry.log(InfoLevel, fmt.Sprintln(args...))
 		logger.releaseEntry(entry)
 	}
 }
 
 func (logger *Logger) Println(args ...interface{}) {
 	entry := logger.newEntry()
-	entry.log(0, logger.Level, fmt.Sprintln(args...))
+	entry.log(logger.Level, fmt.Sprintln(args...))
 	logger.releaseEntry(entry)
 }
 
 func (logger *Logger) Warnln(args ...interface{}) {
 	if logger.Level >= WarnLevel {
 		entry := logger.newEntry()
-		entry.log(0, WarnLevel, fmt.Sprintln(args...))
+		entry.log(WarnLevel, fmt.Sprintln(args...))
 		logger.releaseEntry(entry)
 	}
 }
@@ -422,7 +635,7 @@ func (logger *Logger) Warnln(args ...interface{}) {
 func (logger *Logger) Warningln(args ...interface{}) {
 	if logger.Level >= WarnLevel {
 		entry := logger.newEntry()
-		entry.log(0, WarnLevel, fmt.Sprintln(args...))
+		entry.log(WarnLevel, fmt.Sprintln(args...))
 		logger.releaseEntry(entry)
 	}
 }
@@ -430,7 +643,7 @@ func (logger *Logger) Warningln(args ...interface{}) {
 func (logger *Logger) Errorln(args ...interface{}) {
 	if logger.Level >= ErrorLevel {
 		entry := logger.newEntry()
-		entry.log(0, ErrorLevel, fmt.Sprintln(args...))
+		entry.log(ErrorLevel, fmt.Sprintln(args...))
 		logger.releaseEntry(entry)
 	}
 }
@@ -438,7 +651,7 @@ func (logger *Logger) Errorln(args ...interface{}) {
 func (logger *Logger) Fatalln(args ...interface{}) {
 	if logger.Level >= FatalLevel {
 		entry := logger.newEntry()
-		entry.log(0, FatalLevel, fmt.Sprintln(args...))
+		entry.log(FatalLevel, fmt.Sprintln(args...))
 		logger.releaseEntry(entry)
 	}
 }
@@ -446,7 +659,7 @@ func (logger *Logger) Fatalln(args ...interface{}) {
 func (logger *Logger) Panicln(args ...interface{}) {
 	if logger.Level >= PanicLevel {
 		entry := logger.newEntry()
-		entry.log(0, PanicLevel, fmt.Sprintln(args...))
+		entry.log(PanicLevel, fmt.Sprintln(args...))
 		logger.releaseEntry(entry)
 	}
 }
@@ -455,5 +668,5 @@ func (logger *Logger) Panicln(args ...interface{}) {
 //write concurrently to a file (within 4k message on Linux).
 //In these cases user can choose to disable the lock.
 func (logger *Logger) SetNoLock() {
-	logger.mu.Disable()
+	logger.root().mu.Disable()
 }
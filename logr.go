@@ -0,0 +1,90 @@
+package zlog
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+)
+
+// LogrSink makes a Logger usable as a logr.LogSink, the logging interface of
+// the Kubernetes client libraries and of controller-runtime:
+//
+//	log := logr.New(zlog.NewLogrSink(logger))
+//
+// Verbosity 0 is logged at Info, any higher verbosity at Debug. Names given
+// with WithName are appended to the module name.
+type LogrSink struct {
+	entry     *Entry
+	callDepth int
+}
+
+var (
+	_ logr.LogSink          = &LogrSink{}
+	_ logr.CallDepthLogSink = &LogrSink{}
+)
+
+func NewLogrSink(logger *Logger) *LogrSink {
+	return &LogrSink{entry: NewEntry(logger, logger.moduleName)}
+}
+
+func (s *LogrSink) Init(info logr.RuntimeInfo) {
+	s.callDepth = info.CallDepth
+}
+
+func logrLevel(level int) Level {
+	if level > 0 {
+		return DebugLevel
+	}
+	return InfoLevel
+}
+
+func (s *LogrSink) Enabled(level int) bool {
+	return s.entry.Logger.Level >= logrLevel(level)
+}
+
+func (s *LogrSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	s.log(logrLevel(level), msg, keyValueFields(keysAndValues))
+}
+
+func (s *LogrSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	fields := keyValueFields(keysAndValues)
+	fields[ErrorKey] = err
+	s.log(ErrorLevel, msg, fields)
+}
+
+func (s *LogrSink) log(level Level, msg string, fields Fields) {
+	entry := s.entry.WithFields(fields)
+	if entry.Logger.ReportCaller {
+		// The frames of logr are skipped along with ours.
+		entry.Caller = entry.Logger.getCallerSkip(s.callDepth)
+	}
+	entry.logLevel(level, msg)
+}
+
+func (s *LogrSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &LogrSink{entry: s.entry.WithFields(keyValueFields(keysAndValues)), callDepth: s.callDepth}
+}
+
+func (s *LogrSink) WithName(name string) logr.LogSink {
+	return &LogrSink{entry: s.entry.WithField(moduleKey, fmt.Sprintf("%v/%s", s.entry.Data[moduleKey], name)), callDepth: s.callDepth}
+}
+
+func (s *LogrSink) WithCallDepth(depth int) logr.LogSink {
+	return &LogrSink{entry: s.entry, callDepth: s.callDepth + depth}
+}
+
+// keyValueFields turns the alternating keys and values of logr and similar
+// APIs into Fields, a value missing at the end is logged as an empty string
+// like AddFields does.
+func keyValueFields(keysAndValues []interface{}) Fields {
+	fields := make(Fields, len(keysAndValues)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key := fmt.Sprint(keysAndValues[i])
+		if i+1 < len(keysAndValues) {
+			fields[key] = keysAndValues[i+1]
+		} else {
+			fields[key] = ""
+		}
+	}
+	return fields
+}
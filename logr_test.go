@@ -0,0 +1,68 @@
+package zlog
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogrSink(t *testing.T) {
+	logger, entries := newEntryChannelLogger("logr", 3)
+	logger.SetLevel(InfoLevel)
+
+	log := logr.New(NewLogrSink(logger)).WithName("controller").WithValues("namespace", "default")
+	log.Info("reconciling", "pod", "web-1")
+	log.V(1).Info("hidden at info")
+	log.Error(errors.New("conflict"), "update failed", "retry")
+	close(entries)
+
+	entry := <-entries
+	assert.Equal(t, InfoLevel, entry.level)
+	assert.Equal(t, "reconciling", entry.msg)
+	assert.Equal(t, "logr/controller", entry.data[moduleKey])
+	assert.Equal(t, "default", entry.data["namespace"])
+	assert.Equal(t, "web-1", entry.data["pod"])
+
+	entry = <-entries
+	assert.Equal(t, ErrorLevel, entry.level)
+	assert.Equal(t, "conflict", entry.data[ErrorKey].(error).Error())
+	assert.Equal(t, "", entry.data["retry"])
+
+	_, more := <-entries
+	assert.False(t, more)
+}
+
+func TestLogrSinkVerbosity(t *testing.T) {
+	logger, _ := newEntryChannelLogger("logr", 0)
+	log := logr.New(NewLogrSink(logger))
+
+	logger.SetLevel(InfoLevel)
+	assert.True(t, log.V(0).Enabled())
+	assert.False(t, log.V(1).Enabled())
+
+	logger.SetLevel(DebugLevel)
+	assert.True(t, log.V(4).Enabled())
+}
+
+func TestLogrSinkCaller(t *testing.T) {
+	var input FormatterInput
+	logger := New("logr")
+	logger.ReportCaller = true
+	logger.Formatter = formatterFunc(func(entry FormatterInput) {
+		input = entry
+	})
+
+	log := logr.New(NewLogrSink(logger))
+	line := currentLine() + 1
+	log.Info("caller")
+	assert.Equal(t, line, input.GetCaller().Line)
+
+	helper := func() {
+		log.WithCallDepth(1).Info("through a helper")
+	}
+	line = currentLine() + 1
+	helper()
+	assert.Equal(t, line, input.GetCaller().Line)
+}
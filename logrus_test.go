@@ -2,8 +2,6 @@ package zlog
 
 import (
 	"bytes"
-	"encoding/json"
-	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -22,49 +20,43 @@ func LogAndAssertText(t *testing.T, log func(*Logger), assertions func(fields ma
 
 	log(logger)
 
+	assertions(parseTextFields(buffer.String()))
+}
+
+// parseTextFields reads the "- key = value" lines of TextFormatter.
+func parseTextFields(output string) map[string]string {
 	fields := make(map[string]string)
-	for _, kv := range strings.Split(buffer.String(), " ") {
-		if !strings.Contains(kv, "=") {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "- ") || !strings.Contains(line, " = ") {
 			continue
 		}
-		kvArr := strings.Split(kv, "=")
-		key := strings.TrimSpace(kvArr[0])
-		val := kvArr[1]
-		if kvArr[1][0] == '"' {
-			var err error
-			val, err = strconv.Unquote(val)
-			assert.NoError(t, err)
-		}
-		fields[key] = val
+		kv := strings.SplitN(strings.TrimPrefix(line, "- "), " = ", 2)
+		fields[strings.TrimSpace(kv[0])] = kv[1]
 	}
-	assertions(fields)
+	return fields
 }
 
-
 func TestWithFieldsShouldAllowAssignments(t *testing.T) {
 	var buffer bytes.Buffer
-	var fields Fields
 
 	logger := New()
 	logger.Out = &buffer
-	logger.Formatter = new(TextFormatter)
+	logger.Formatter = &TextFormatter{DisableColors: true}
 
 	localLog := logger.WithFields(Fields{
 		"key1": "value1",
 	})
 
 	localLog.WithField("key2", "value2").Info("test")
-	err := json.Unmarshal(buffer.Bytes(), &fields)
-	assert.Nil(t, err)
+	fields := parseTextFields(buffer.String())
 
 	assert.Equal(t, "value2", fields["key2"])
 	assert.Equal(t, "value1", fields["key1"])
 
-	buffer = bytes.Buffer{}
-	fields = Fields{}
+	buffer.Reset()
 	localLog.Info("test")
-	err = json.Unmarshal(buffer.Bytes(), &fields)
-	assert.Nil(t, err)
+	fields = parseTextFields(buffer.String())
 
 	_, ok := fields["key2"]
 	assert.Equal(t, false, ok)
@@ -77,6 +69,7 @@ func TestDefaultFieldsAreNotPrefixed(t *testing.T) {
 		ll.Info("hello")
 		ll.Info("bye")
 	}, func(fields map[string]string) {
+		assert.Equal(t, "derp", fields["herp"])
 		for _, fieldName := range []string{"fields.level", "fields.time", "fields.msg"} {
 			if _, ok := fields[fieldName]; ok {
 				t.Fatalf("should not have prefixed %q: %v", fieldName, fields)
@@ -88,42 +81,36 @@ func TestDefaultFieldsAreNotPrefixed(t *testing.T) {
 func TestDoubleLoggingDoesntPrefixPreviousFields(t *testing.T) {
 
 	var buffer bytes.Buffer
-	var fields Fields
 
 	logger := New()
 	logger.Out = &buffer
-	logger.Formatter = new(TextFormatter)
+	logger.Formatter = &TextFormatter{DisableColors: true}
 
 	llog := logger.WithField("context", "eating raw fish")
 
 	llog.Info("looks delicious")
 
-	err := json.Unmarshal(buffer.Bytes(), &fields)
-	assert.NoError(t, err, "should have decoded first message")
-	assert.Equal(t, len(fields), 4, "should only have msg/time/level/context fields")
-	assert.Equal(t, fields["msg"], "looks delicious")
-	assert.Equal(t, fields["context"], "eating raw fish")
+	fields := parseTextFields(buffer.String())
+	assert.Contains(t, buffer.String(), "looks delicious")
+	assert.Equal(t, map[string]string{"context": "eating raw fish"}, fields, "should only have the context field")
 
 	buffer.Reset()
 
 	llog.Warn("omg it is!")
 
-	err = json.Unmarshal(buffer.Bytes(), &fields)
-	assert.NoError(t, err, "should have decoded second message")
-	assert.Equal(t, len(fields), 4, "should only have msg/time/level/context fields")
-	assert.Equal(t, fields["msg"], "omg it is!")
-	assert.Equal(t, fields["context"], "eating raw fish")
-	assert.Nil(t, fields["fields.msg"], "should not have prefixed previous `msg` entry")
+	fields = parseTextFields(buffer.String())
+	assert.Contains(t, buffer.String(), "omg it is!")
+	assert.Equal(t, map[string]string{"context": "eating raw fish"}, fields, "should not have prefixed previous `msg` entry")
 
 }
 
 func TestConvertLevelToString(t *testing.T) {
-	assert.Equal(t, "debug", DebugLevel.String())
-	assert.Equal(t, "info", InfoLevel.String())
-	assert.Equal(t, "warning", WarnLevel.String())
-	assert.Equal(t, "error", ErrorLevel.String())
-	assert.Equal(t, "fatal", FatalLevel.String())
-	assert.Equal(t, "panic", PanicLevel.String())
+	assert.Equal(t, "     ", DebugLevel.String())
+	assert.Equal(t, "---> ", InfoLevel.String())
+	assert.Equal(t, "**** ", WarnLevel.String())
+	assert.Equal(t, ">>>> ", ErrorLevel.String())
+	assert.Equal(t, ">>>> ", FatalLevel.String())
+	assert.Equal(t, ">>>> ", PanicLevel.String())
 }
 
 func TestParseLevel(t *testing.T) {
@@ -184,7 +171,7 @@ func TestParseLevel(t *testing.T) {
 	assert.Equal(t, DebugLevel, l)
 
 	l, err = ParseLevel("invalid")
-	assert.Equal(t, "not a valid logrus Level: \"invalid\"", err.Error())
+	assert.Equal(t, "not a valid zlog Level: \"invalid\"", err.Error())
 }
 
 func TestGetSetLevelRace(t *testing.T) {
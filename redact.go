@@ -0,0 +1,316 @@
+package zlog
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// RedactMode is what a RedactRule does to the values it matches.
+type RedactMode int
+
+const (
+	// Replace the value with REDACTED.
+	RedactMask RedactMode = iota
+	// Replace the value with a short keyed hash of it, so that the entries
+	// about a same value can still be related, see RedactRule.HashKey.
+	RedactHash
+	// Leave the field out. Values found in messages are masked.
+	RedactDrop
+)
+
+const redactedValue = "REDACTED"
+
+// processHashKey is the key of the rules without a HashKey, drawn once per
+// process.
+var processHashKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+// A RedactRule selects the sensitive values of entries by the key they are
+// logged with, or by what they look like. See Logger.RedactRules.
+type RedactRule struct {
+	// Keys of fields and of the objects of JSON raws, as case insensitive
+	// glob patterns like "*token*".
+	Keys []string
+	// Keys matching the expression.
+	KeyRegexp *regexp.Regexp
+
+	// Values found in messages, fields, labels and the strings of JSON raws.
+	// When the expression has a subexpression, only what the subexpression
+	// matches is replaced.
+	//
+	// Fields which are not strings, errors, booleans or nil are searched as
+	// fmt.Sprint prints them, which costs a formatting of each of them on
+	// every entry. When something is found, the field is logged as the
+	// redacted text and loses its type.
+	Values *regexp.Regexp
+
+	Mode RedactMode
+
+	// Key of the HMAC-SHA256 of RedactHash. The values of emails or card
+	// numbers are few enough to be enumerated, a plain hash would give them
+	// away. Without a key, one is drawn for the process, and the hashes only
+	// relate the entries of a same run.
+	HashKey []byte
+
+	// Further checks what Values found, against false positives.
+	validate func(string) bool
+}
+
+// Rules for the usual suspects, add them to Logger.RedactRules. Copies can be
+// given another Mode.
+var (
+	RedactSecretKeys = RedactRule{Keys: []string{
+		"*password*", "*passwd*", "*secret*", "*token*", "*api_key*", "*apikey*", "authorization", "cookie",
+	}}
+	RedactEmails       = RedactRule{Values: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)}
+	RedactCardNumbers  = RedactRule{Values: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), validate: luhnValid}
+	RedactJWTs         = RedactRule{Values: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)}
+	RedactBearerTokens = RedactRule{Values: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]+=*)`)}
+)
+
+func (r *RedactRule) matchesKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, pattern := range r.Keys {
+		if ok, _ := path.Match(strings.ToLower(pattern), lower); ok {
+			return true
+		}
+	}
+	return r.KeyRegexp != nil && r.KeyRegexp.MatchString(key)
+}
+
+func (r *RedactRule) mask(value string) string {
+	if r.Mode == RedactHash {
+		key := r.HashKey
+		if len(key) == 0 {
+			key = processHashKey
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(value))
+		return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:6])
+	}
+	return redactedValue
+}
+
+func (r *RedactRule) redactString(s string) string {
+	if r.Values == nil {
+		return s
+	}
+	matches := r.Values.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) >= 4 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		if r.validate != nil && !r.validate(s[start:end]) {
+			continue
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(r.mask(s[start:end]))
+		last = end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+type redactRules []RedactRule
+
+func (rules redactRules) keyRule(key string) *RedactRule {
+	for i := range rules {
+		if rules[i].matchesKey(key) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+func (rules redactRules) matchValues() bool {
+	for i := range rules {
+		if rules[i].Values != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// text redacts the values found in s, dropped tells whether a RedactDrop rule
+// found one.
+func (rules redactRules) text(s string) (redacted string, dropped bool) {
+	for i := range rules {
+		r := rules[i].redactString(s)
+		if r != s && rules[i].Mode == RedactDrop {
+			dropped = true
+		}
+		s = r
+	}
+	return s, dropped
+}
+
+// field returns the value to log for a field, false when it is left out.
+func (rules redactRules) field(key string, value interface{}) (interface{}, bool) {
+	if rule := rules.keyRule(key); rule != nil {
+		if rule.Mode == RedactDrop {
+			return nil, false
+		}
+		return rule.mask(fmt.Sprint(value)), true
+	}
+
+	var s string
+	switch v := value.(type) {
+	case nil, bool:
+		return value, true
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	default:
+		if !rules.matchValues() {
+			return value, true
+		}
+		// Maps, slices, structs and Stringers can hold a value anywhere.
+		s = fmt.Sprint(v)
+	}
+	redacted, dropped := rules.text(s)
+	if dropped {
+		return nil, false
+	}
+	if redacted == s {
+		return value, true
+	}
+	return redacted, true
+}
+
+// json redacts a JSON raw, which is left as is when nothing is redacted. A
+// raw which is not JSON is redacted as text.
+func (rules redactRules) json(raw []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		s, _ := rules.text(string(raw))
+		return []byte(s)
+	}
+
+	r := &jsonRedactor{rules: rules}
+	v, _ = r.value(v)
+	if !r.changed {
+		return raw
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return []byte(redactedValue)
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+}
+
+type jsonRedactor struct {
+	rules   redactRules
+	changed bool
+}
+
+func (r *jsonRedactor) value(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if rule := r.rules.keyRule(k); rule != nil {
+				r.changed = true
+				if rule.Mode == RedactDrop {
+					delete(v, k)
+				} else {
+					v[k] = rule.mask(fmt.Sprint(child))
+				}
+				continue
+			}
+			if child, ok := r.value(child); ok {
+				v[k] = child
+			} else {
+				delete(v, k)
+			}
+		}
+	case []interface{}:
+		kept := v[:0]
+		for _, child := range v {
+			if child, ok := r.value(child); ok {
+				kept = append(kept, child)
+			}
+		}
+		return kept, true
+	case string:
+		redacted, dropped := r.rules.text(v)
+		if redacted != v {
+			r.changed = true
+		}
+		return redacted, !dropped
+	}
+	return v, true
+}
+
+// redact applies the rules of the logger to what the formatter is given. The
+// data and labels are copied, entries share theirs with the entries they were
+// derived from.
+func (entry *Entry) redact(rules redactRules) {
+	data := make(Fields, len(entry.Data))
+	for k, v := range entry.Data {
+		if k == moduleKey {
+			data[k] = v
+			continue
+		}
+		if v, ok := rules.field(k, v); ok {
+			data[k] = v
+		}
+	}
+	entry.Data = data
+	if entry.Labels != nil {
+		labels := make(map[string]string, len(entry.Labels))
+		for k, v := range entry.Labels {
+			if v, ok := rules.field(k, v); ok {
+				labels[k] = v.(string)
+			}
+		}
+		entry.Labels = labels
+	}
+	entry.Message, _ = rules.text(entry.Message)
+	if entry.JsonRawList != nil {
+		entry.JsonRawList = rules.json(entry.JsonRawList)
+	}
+}
+
+// luhnValid tells whether the digits of s pass the checksum of card numbers.
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] == ' ' || s[i] == '-' {
+			continue
+		}
+		d := int(s[i] - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
@@ -34,6 +34,16 @@ func (f *SeverityFormatter) Format(entry *Entry) ([]byte, error) {
 	data["message"] = entry.Message
 	data["level"] = entry.Level.String()
 	data["severity"] = strings.ToUpper(entry.Level.String())
+	if entry.Caller != nil {
+		data["file"] = fmt.Sprintf("%s:%d", entry.Caller.File, entry.Caller.Line)
+		data["func"] = entry.Caller.Function
+	}
+	if entry.Goroutine != 0 {
+		data["goroutine"] = entry.Goroutine
+	}
+	if len(entry.Labels) > 0 {
+		data["labels"] = entry.Labels
+	}
 
 	serialized, err := json.Marshal(data)
 	if err != nil {
@@ -63,7 +63,7 @@ func TestSeverityFieldClashWithTime(t *testing.T) {
 		t.Fatal("fields.time not set to original time field")
 	}
 
-	if entry["time"] != "0001-01-01T00:00:00Z" {
+	if entry["time"] != "0001-01-01 00:00:00" {
 		t.Fatal("time field not set to current time, was: ", entry["time"])
 	}
 }
@@ -0,0 +1,224 @@
+//go:build go1.21
+// +build go1.21
+
+package zlog
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// SlogOptions configures a SlogHandler.
+type SlogOptions struct {
+	// Log the attributes of groups as nested objects in the JSON raw of the
+	// entries, instead of as fields with dotted keys like "request.method".
+	NestGroups bool
+}
+
+// SlogHandler is a slog.Handler writing through a zlog Logger, with its
+// level, formatter and output, so that libraries taking a *slog.Logger log
+// like the rest of the program:
+//
+//	lib.New(slog.New(zlog.NewSlogHandler(logger, nil)))
+type SlogHandler struct {
+	logger *Logger
+	opts   SlogOptions
+	// What WithGroup and WithAttrs were called with, in order
+	groups []string
+	attrs  []groupedAttrs
+}
+
+type groupedAttrs struct {
+	groups []string
+	attrs  []slog.Attr
+}
+
+func NewSlogHandler(logger *Logger, opts *SlogOptions) *SlogHandler {
+	h := &SlogHandler{logger: logger}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.logger.Level >= levelFromSlog(level)
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	fields := make(Fields, r.NumAttrs())
+	nested := make(map[string]interface{})
+	for _, ga := range h.attrs {
+		for _, attr := range ga.attrs {
+			h.addAttr(fields, nested, ga.groups, attr)
+		}
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		h.addAttr(fields, nested, h.groups, attr)
+		return true
+	})
+
+	entry := h.logger.WithFields(fields)
+	if len(nested) > 0 {
+		bs, err := json.Marshal(nested)
+		if err != nil {
+			return err
+		}
+		entry = entry.WithJsonRaw(bs)
+	}
+	// slog knows its caller already, the stack would only lead to slog itself.
+	if h.logger.ReportCaller && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		entry.Caller = &frame
+	}
+	entry.logLevel(levelFromSlog(r.Level), r.Message)
+	return nil
+}
+
+func (h *SlogHandler) addAttr(fields Fields, nested map[string]interface{}, groups []string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			groups = append(groups[:len(groups):len(groups)], attr.Key)
+		}
+		for _, a := range attr.Value.Group() {
+			h.addAttr(fields, nested, groups, a)
+		}
+		return
+	}
+
+	value := attr.Value.Any()
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	switch {
+	case len(groups) == 0:
+		fields[attr.Key] = value
+	case h.opts.NestGroups:
+		m := nested
+		for _, group := range groups {
+			child, ok := m[group].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				m[group] = child
+			}
+			m = child
+		}
+		m[attr.Key] = value
+	default:
+		fields[strings.Join(groups, ".")+"."+attr.Key] = value
+	}
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = append(h.attrs[:len(h.attrs):len(h.attrs)], groupedAttrs{groups: h.groups, attrs: attrs})
+	return &h2
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+	return &h2
+}
+
+func levelFromSlog(level slog.Level) Level {
+	switch {
+	case level >= slog.LevelError:
+		return ErrorLevel
+	case level >= slog.LevelWarn:
+		return WarnLevel
+	case level >= slog.LevelInfo:
+		return InfoLevel
+	default:
+		return DebugLevel
+	}
+}
+
+func levelToSlog(level Level) slog.Level {
+	switch level {
+	case DebugLevel:
+		return slog.LevelDebug
+	case InfoLevel:
+		return slog.LevelInfo
+	case WarnLevel:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+// SlogFormatter hands the entries of a Logger to a slog.Handler instead of
+// formatting them, so zlog can feed whatever a slog based program uses. The
+// fields become attributes, the module a "module" attribute and the JSON raw
+// a "json" attribute. Nothing is written to the logger's Out.
+type SlogFormatter struct {
+	Handler slog.Handler
+}
+
+func (f *SlogFormatter) Format(entry FormatterInput) ([]byte, error) {
+	ctx := context.Background()
+	level := levelToSlog(entry.GetLevel())
+	if !f.Handler.Enabled(ctx, level) {
+		return nil, nil
+	}
+
+	var pc uintptr
+	if caller := entry.GetCaller(); caller != nil {
+		pc = caller.PC
+	}
+	r := slog.NewRecord(entry.GetTime(), level, entry.GetMessage(), pc)
+	data := entry.GetData()
+	if module, ok := data[moduleKey]; ok {
+		r.AddAttrs(slog.Any("module", module))
+	}
+	for _, k := range sortedKeys(data) {
+		if k != moduleKey {
+			r.AddAttrs(slog.Any(k, data[k]))
+		}
+	}
+	if id := entry.GetGoroutine(); id != 0 {
+		r.AddAttrs(slog.Int64("goroutine", id))
+	}
+	if labels := entry.GetLabels(); len(labels) > 0 {
+		keys := make([]string, 0, len(labels))
+		for k := range labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		attrs := make([]interface{}, 0, len(labels))
+		for _, k := range keys {
+			attrs = append(attrs, slog.String(k, labels[k]))
+		}
+		r.AddAttrs(slog.Group("labels", attrs...))
+	}
+	if jsonRaw := entry.GetJsonRaw(); jsonRaw != nil {
+		r.AddAttrs(slog.Any("json", json.RawMessage(jsonRaw)))
+	}
+	return nil, f.Handler.Handle(ctx, r)
+}
+
+// sortedKeys orders the attributes as TextFormatter orders the fields, so
+// that a same entry is always handled the same.
+func sortedKeys(data Fields) []string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,105 @@
+//go:build go1.21
+// +build go1.21
+
+package zlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogHandler(t *testing.T) {
+	logger, entries := newEntryChannelLogger("slog", 2)
+	logger.SetLevel(InfoLevel)
+
+	l := slog.New(NewSlogHandler(logger, nil)).With("service", "api").WithGroup("request")
+	l.Debug("hidden")
+	l.Warn("slow", "method", "GET", slog.Group("timing", "ms", 12), "err", errors.New("timeout"))
+	close(entries)
+
+	entry := <-entries
+	assert.Equal(t, WarnLevel, entry.level)
+	assert.Equal(t, "slow", entry.msg)
+	assert.Equal(t, "api", entry.data["service"])
+	assert.Equal(t, "GET", entry.data["request.method"])
+	assert.Equal(t, int64(12), entry.data["request.timing.ms"])
+	assert.Equal(t, "timeout", entry.data["request.err"])
+	_, more := <-entries
+	assert.False(t, more)
+}
+
+func TestSlogHandlerNestGroups(t *testing.T) {
+	var raw []byte
+	var data Fields
+	logger := New("slog")
+	logger.Formatter = formatterFunc(func(entry FormatterInput) {
+		raw = entry.GetJsonRaw()
+		data = entry.GetData()
+	})
+
+	l := slog.New(NewSlogHandler(logger, &SlogOptions{NestGroups: true}))
+	l.Info("nested", "top", 1, slog.Group("request", "method", "GET", slog.Group("timing", "ms", 12)))
+
+	assert.Equal(t, int64(1), data["top"])
+	assert.JSONEq(t, `{"request":{"method":"GET","timing":{"ms":12}}}`, string(raw))
+}
+
+func TestSlogHandlerReportsSlogCaller(t *testing.T) {
+	var input FormatterInput
+	logger := New("slog")
+	logger.ReportCaller = true
+	logger.Formatter = formatterFunc(func(entry FormatterInput) {
+		input = entry
+	})
+
+	line := currentLine() + 1
+	slog.New(NewSlogHandler(logger, nil)).Info("caller")
+	if assert.NotNil(t, input.GetCaller()) {
+		assert.Equal(t, line, input.GetCaller().Line)
+		assert.True(t, strings.HasSuffix(input.GetCaller().File, "slog_test.go"))
+	}
+}
+
+func TestSlogFormatter(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := New("formatter")
+	logger.Out = &bytes.Buffer{}
+	logger.Formatter = &SlogFormatter{Handler: slog.NewJSONHandler(&buffer, nil)}
+
+	logger.WithField("user", "ann").WithJsonRaw([]byte(`{"a":1}`)).Warn("forwarded")
+	logger.Debug("below the handler's level")
+
+	var record map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buffer.Bytes(), &record))
+	assert.Equal(t, "WARN", record["level"])
+	assert.Equal(t, "forwarded", record["msg"])
+	assert.Equal(t, "formatter", record["module"])
+	assert.Equal(t, map[string]interface{}{"a": float64(1)}, record["json"])
+	assert.Equal(t, 0, logger.Out.(*bytes.Buffer).Len())
+}
+
+func TestSlogFormatterSortsAttrs(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := New("formatter")
+	noTime := func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey {
+			return slog.Attr{}
+		}
+		return a
+	}
+	logger.Formatter = &SlogFormatter{Handler: slog.NewTextHandler(&buffer, &slog.HandlerOptions{ReplaceAttr: noTime})}
+
+	entry := logger.WithFields(Fields{"d": 4, "b": 2, "c": 3, "a": 1})
+	entry.Labels = map[string]string{"y": "2", "x": "1", "z": "3"}
+	for i := 0; i < 10; i++ {
+		buffer.Reset()
+		entry.Info("sorted")
+		assert.Equal(t, "level=INFO msg=sorted module=formatter a=1 b=2 c=3 d=4 labels.x=1 labels.y=2 labels.z=3\n", buffer.String())
+	}
+}
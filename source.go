@@ -0,0 +1,122 @@
+package zlog
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultSourceContext = 2
+	maxCachedSourceFiles = 64
+)
+
+// Source files are read once and kept around, a program usually logs from a
+// handful of places over and over again. The least recently used file is
+// dropped past maxCachedSourceFiles.
+var sources = newSourceCache(maxCachedSourceFiles)
+
+type sourceCache struct {
+	mu    sync.Mutex
+	max   int
+	files map[string]*list.Element
+	// Most recently used first, of *sourceFile.
+	order *list.List
+}
+
+// sourceFile is read once, outside the lock of the cache, by the first
+// logger asking for it. A file which cannot be read is kept as well, with its
+// error, so that a binary deployed without its sources does not try again on
+// every entry.
+type sourceFile struct {
+	name  string
+	once  sync.Once
+	lines []string
+	err   error
+}
+
+func newSourceCache(max int) *sourceCache {
+	return &sourceCache{max: max, files: make(map[string]*list.Element), order: list.New()}
+}
+
+func (c *sourceCache) lines(file string) ([]string, error) {
+	f := c.file(file)
+	f.once.Do(f.read)
+	return f.lines, f.err
+}
+
+func (c *sourceCache) file(file string) *sourceFile {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.files[file]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*sourceFile)
+	}
+
+	if c.order.Len() >= c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.files, oldest.Value.(*sourceFile).name)
+	}
+	f := &sourceFile{name: file}
+	c.files[file] = c.order.PushFront(f)
+	return f
+}
+
+func (f *sourceFile) read() {
+	file, err := os.Open(f.name)
+	if err != nil {
+		f.err = err
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		f.lines = append(f.lines, scanner.Text())
+	}
+	f.err = scanner.Err()
+}
+
+// writeSourceSnippet prints the lines around file:line, the line itself is
+// marked with '>' and, when color is not zero, highlighted with it.
+func writeSourceSnippet(b *bytes.Buffer, file string, line, context int, color Color) {
+	lines, err := sources.lines(file)
+	if err != nil || line <= 0 || line > len(lines) {
+		return
+	}
+	if context <= 0 {
+		context = defaultSourceContext
+	}
+
+	first := line - context
+	if first < 1 {
+		first = 1
+	}
+	last := line + context
+	if last > len(lines) {
+		last = len(lines)
+	}
+
+	prefix := "      "
+	for n := first; n <= last; n++ {
+		text := strings.Replace(lines[n-1], "\t", "    ", -1)
+		marker := " "
+		if n == line {
+			marker = ">"
+		}
+		switch {
+		case color == nocolor:
+			fmt.Fprintf(b, "\n%s%s %4d | %s", prefix, marker, n, text)
+		case n == line:
+			fmt.Fprintf(b, "\n%s\x1b[%sm%s %4d | %s\x1b[0m", prefix, color, marker, n, text)
+		default:
+			fmt.Fprintf(b, "\n%s\x1b[%sm%s %4d | %s\x1b[0m", prefix, gray, marker, n, text)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package zlog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceSnippetOnError(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger := New("snippet")
+	logger.Out = &buffer
+	logger.Formatter = &TextFormatter{DisableColors: true, ShowSource: true, SourceContext: 1}
+
+	logger.Info("no source for info")
+	assert.NotContains(t, buffer.String(), "| ")
+
+	buffer.Reset()
+	logger.Error("source for error") // marked line
+	lines := strings.Split(buffer.String(), "\n")
+	assert.Len(t, lines, 5)
+	assert.Contains(t, lines[2], `>`)
+	assert.Contains(t, lines[2], `logger.Error("source for error") // marked line`)
+}
+
+func TestSourceSnippetPerModule(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger := New("snippet")
+	logger.Out = &buffer
+	logger.Formatter = &TextFormatter{DisableColors: true, SourceModules: []string{"snippet"}}
+
+	logger.Debug("source for module")
+	assert.Contains(t, buffer.String(), `logger.Debug("source for module")`)
+
+	buffer.Reset()
+	other := New("other")
+	other.Out = &buffer
+	other.Formatter = logger.Formatter
+	other.Debug("no source for other module")
+	assert.NotContains(t, buffer.String(), "| ")
+}
+
+func TestSourceSnippetMissingFile(t *testing.T) {
+	var buffer bytes.Buffer
+	writeSourceSnippet(&buffer, "does/not/exist.go", 10, 2, nocolor)
+	assert.Equal(t, 0, buffer.Len())
+}
+
+func TestSourceCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newSourceCache(2)
+	for _, file := range []string{"source.go", "caller.go", "source.go", "stack.go"} {
+		_, err := cache.lines(file)
+		assert.NoError(t, err)
+	}
+	assert.Len(t, cache.files, 2)
+	assert.Contains(t, cache.files, "source.go")
+	assert.Contains(t, cache.files, "stack.go")
+	assert.NotContains(t, cache.files, "caller.go")
+}
+
+func TestSourceCacheKeepsMissingFiles(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "later.go")
+	cache := newSourceCache(2)
+	_, err := cache.lines(file)
+	assert.Error(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(file, []byte("package later\n"), 0644))
+	_, err = cache.lines(file)
+	assert.Error(t, err, "the failed read is not tried again")
+}
@@ -0,0 +1,386 @@
+package zlog
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxSQLArgSize = 64
+
+// SQLOptions configures the drivers returned by WrapSQLDriver and
+// WrapSQLConnector.
+type SQLOptions struct {
+	// Statements and transactions lasting longer are logged at Warn instead
+	// of Debug, no threshold when zero.
+	SlowQuery time.Duration
+
+	// Leave the bound arguments out of the log.
+	HideArgs bool
+	// Arguments logged as REDACTED, named arguments by name and positional
+	// ones by ordinal like "$2".
+	RedactArgs []string
+}
+
+// WrapSQLDriver returns a database/sql driver logging every statement executed
+// through d: its SQL, arguments, affected rows, duration and error. Failed
+// statements are logged at Error, the others at Debug. Statements executed
+// with a context carrying an entry, see ContextWithEntry, are logged with its
+// fields.
+//
+//	sql.Register("postgres-logged", zlog.WrapSQLDriver(&pq.Driver{}, logger, zlog.SQLOptions{}))
+func WrapSQLDriver(d driver.Driver, logger *Logger, opts SQLOptions) driver.Driver {
+	return &sqlDriver{Driver: d, l: &sqlLogger{logger: logger, opts: opts}}
+}
+
+// WrapSQLConnector is WrapSQLDriver for drivers opened with sql.OpenDB.
+func WrapSQLConnector(c driver.Connector, logger *Logger, opts SQLOptions) driver.Connector {
+	return &sqlConnector{Connector: c, l: &sqlLogger{logger: logger, opts: opts}}
+}
+
+type sqlLogger struct {
+	logger *Logger
+	opts   SQLOptions
+}
+
+func (l *sqlLogger) log(ctx context.Context, op, query string, args []driver.NamedValue, start time.Time, result driver.Result, err error) {
+	// The statement is done again another way, it is logged then.
+	if err == driver.ErrSkip {
+		return
+	}
+	duration := time.Since(start)
+
+	fields := Fields{"op": op, "duration": duration.String()}
+	level := DebugLevel
+	if err != nil {
+		fields[ErrorKey] = err
+		level = ErrorLevel
+	} else if l.opts.SlowQuery > 0 && duration >= l.opts.SlowQuery {
+		fields["slow"] = true
+		level = WarnLevel
+	}
+
+	entry, ok := ctx.Value(entryContextKey{}).(*Entry)
+	if !ok {
+		entry = NewEntry(l.logger, l.logger.moduleName)
+	}
+	if entry.Logger.Level < level {
+		return
+	}
+
+	if len(args) > 0 && !l.opts.HideArgs {
+		fields["args"] = l.formatArgs(args)
+	}
+	if result != nil {
+		if rows, err := result.RowsAffected(); err == nil {
+			fields["rows"] = rows
+		}
+	}
+	entry.WithFields(fields).log(level, strings.Join(strings.Fields(query), " "))
+}
+
+func (l *sqlLogger) formatArgs(args []driver.NamedValue) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		value := "REDACTED"
+		if !l.redacts(arg) {
+			value = formatSQLArg(arg.Value)
+		}
+		if arg.Name != "" {
+			value = arg.Name + "=" + value
+		}
+		parts[i] = value
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (l *sqlLogger) redacts(arg driver.NamedValue) bool {
+	for _, name := range l.opts.RedactArgs {
+		if (arg.Name != "" && name == arg.Name) || name == "$"+strconv.Itoa(arg.Ordinal) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatSQLArg(value driver.Value) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		if len(v) > maxSQLArgSize {
+			n := maxSQLArgSize
+			for n > 0 && !utf8.RuneStart(v[n]) {
+				n--
+			}
+			v = v[:n] + "..."
+		}
+		return strconv.Quote(v)
+	case []byte:
+		return fmt.Sprintf("[%d bytes]", len(v))
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("zlog: the driver does not support named arguments")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+func valuesToNamedValues(values []driver.Value) []driver.NamedValue {
+	args := make([]driver.NamedValue, len(values))
+	for i, value := range values {
+		args[i] = driver.NamedValue{Ordinal: i + 1, Value: value}
+	}
+	return args
+}
+
+type sqlDriver struct {
+	driver.Driver
+	l *sqlLogger
+}
+
+func (d *sqlDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlConn{Conn: conn, l: d.l}, nil
+}
+
+type sqlConnector struct {
+	driver.Connector
+	l *sqlLogger
+}
+
+func (c *sqlConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlConn{Conn: conn, l: c.l}, nil
+}
+
+func (c *sqlConnector) Driver() driver.Driver {
+	return &sqlDriver{Driver: c.Connector.Driver(), l: c.l}
+}
+
+// sqlConn implements all the optional interfaces of driver.Conn, falling back
+// to what database/sql does when the wrapped connection lacks one.
+type sqlConn struct {
+	driver.Conn
+	l *sqlLogger
+}
+
+func (c *sqlConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *sqlConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	start := time.Now()
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else if err = ctx.Err(); err == nil {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		c.l.log(ctx, "prepare", query, nil, start, nil, err)
+		return nil, err
+	}
+	return &sqlStmt{Stmt: stmt, conn: c.Conn, query: query, l: c.l}, nil
+}
+
+func (c *sqlConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *sqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	start := time.Now()
+	var tx driver.Tx
+	var err error
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = b.BeginTx(ctx, opts)
+	} else if opts.Isolation != 0 {
+		err = errors.New("zlog: the driver does not support non-default isolation levels")
+	} else if opts.ReadOnly {
+		err = errors.New("zlog: the driver does not support read-only transactions")
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	if err != nil {
+		c.l.log(ctx, "begin", "BEGIN", nil, start, nil, err)
+		return nil, err
+	}
+	return &sqlTx{Tx: tx, ctx: ctx, start: start, l: c.l}, nil
+}
+
+func (c *sqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	switch q := c.Conn.(type) {
+	case driver.QueryerContext:
+		rows, err = q.QueryContext(ctx, query, args)
+	case driver.Queryer:
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			rows, err = q.Query(query, values)
+		}
+	default:
+		return nil, driver.ErrSkip
+	}
+	c.l.log(ctx, "query", query, args, start, nil, err)
+	return rows, err
+}
+
+func (c *sqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var result driver.Result
+	var err error
+	switch e := c.Conn.(type) {
+	case driver.ExecerContext:
+		result, err = e.ExecContext(ctx, query, args)
+	case driver.Execer:
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			result, err = e.Exec(query, values)
+		}
+	default:
+		return nil, driver.ErrSkip
+	}
+	c.l.log(ctx, "exec", query, args, start, result, err)
+	return result, err
+}
+
+func (c *sqlConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *sqlConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *sqlConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *sqlConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type sqlStmt struct {
+	driver.Stmt
+	// The connection of the driver, database/sql only asks it to check the
+	// arguments when the statement does not.
+	conn  driver.Conn
+	query string
+	l     *sqlLogger
+}
+
+func (s *sqlStmt) Exec(values []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), valuesToNamedValues(values))
+}
+
+func (s *sqlStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var result driver.Result
+	var err error
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = e.ExecContext(ctx, args)
+	} else if err = ctx.Err(); err == nil {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			result, err = s.Stmt.Exec(values)
+		}
+	}
+	s.l.log(ctx, "exec", s.query, args, start, result, err)
+	return result, err
+}
+
+func (s *sqlStmt) Query(values []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), valuesToNamedValues(values))
+}
+
+func (s *sqlStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else if err = ctx.Err(); err == nil {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			rows, err = s.Stmt.Query(values)
+		}
+	}
+	s.l.log(ctx, "query", s.query, args, start, nil, err)
+	return rows, err
+}
+
+func (s *sqlStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	if checker, ok := s.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// ColumnConverter is given to database/sql, which applies it after the
+// driver.Valuer of the arguments and only to the ones the statement says it
+// takes.
+func (s *sqlStmt) ColumnConverter(idx int) driver.ValueConverter {
+	if cc, ok := s.Stmt.(driver.ColumnConverter); ok {
+		return cc.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
+}
+
+// sqlTx logs the end of a transaction with its duration since it began.
+type sqlTx struct {
+	driver.Tx
+	ctx   context.Context
+	start time.Time
+	l     *sqlLogger
+}
+
+func (t *sqlTx) Commit() error {
+	err := t.Tx.Commit()
+	t.l.log(t.ctx, "commit", "COMMIT", nil, t.start, nil, err)
+	return err
+}
+
+func (t *sqlTx) Rollback() error {
+	err := t.Tx.Rollback()
+	t.l.log(t.ctx, "rollback", "ROLLBACK", nil, t.start, nil, err)
+	return err
+}
@@ -0,0 +1,210 @@
+package zlog
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A driver without a database, statements containing "fail" fail and the
+// ones containing "sleep" are slow. Queries go through prepared statements,
+// the ones of statements starting with "UPPER" convert their two arguments
+// to upper case.
+type fakeSQLDriver struct{}
+
+func (fakeSQLDriver) Open(name string) (driver.Conn, error) { return fakeSQLConn{}, nil }
+
+type fakeSQLConnector struct{}
+
+func (fakeSQLConnector) Connect(context.Context) (driver.Conn, error) { return fakeSQLConn{}, nil }
+func (fakeSQLConnector) Driver() driver.Driver                        { return fakeSQLDriver{} }
+
+type fakeSQLConn struct{}
+
+func (fakeSQLConn) Close() error              { return nil }
+func (fakeSQLConn) Begin() (driver.Tx, error) { return fakeSQLTx{}, nil }
+
+func (fakeSQLConn) Prepare(query string) (driver.Stmt, error) {
+	if strings.HasPrefix(query, "UPPER") {
+		return fakeSQLUpperStmt{fakeSQLStmt{query}}, nil
+	}
+	return fakeSQLStmt{query}, nil
+}
+
+func (fakeSQLConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return fakeSQLExec(query)
+}
+
+func fakeSQLExec(query string) (driver.Result, error) {
+	if strings.Contains(query, "sleep") {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if strings.Contains(query, "fail") {
+		return nil, errors.New("syntax error")
+	}
+	return driver.RowsAffected(3), nil
+}
+
+type fakeSQLStmt struct{ query string }
+
+func (fakeSQLStmt) Close() error  { return nil }
+func (fakeSQLStmt) NumInput() int { return -1 }
+
+func (s fakeSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return fakeSQLExec(s.query)
+}
+
+func (s fakeSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if _, err := fakeSQLExec(s.query); err != nil {
+		return nil, err
+	}
+	return &fakeSQLRows{}, nil
+}
+
+type fakeSQLUpperStmt struct{ fakeSQLStmt }
+
+func (fakeSQLUpperStmt) NumInput() int { return 2 }
+
+func (fakeSQLUpperStmt) ColumnConverter(idx int) driver.ValueConverter {
+	return upperConverter{}
+}
+
+type upperConverter struct{}
+
+func (upperConverter) ConvertValue(v interface{}) (driver.Value, error) {
+	if s, ok := v.(string); ok {
+		return strings.ToUpper(s), nil
+	}
+	return nil, fmt.Errorf("unexpected %T", v)
+}
+
+type fakeSQLRows struct{ done bool }
+
+func (*fakeSQLRows) Columns() []string { return []string{"n"} }
+func (*fakeSQLRows) Close() error      { return nil }
+
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+type fakeSQLTx struct{}
+
+func (fakeSQLTx) Commit() error   { return nil }
+func (fakeSQLTx) Rollback() error { return nil }
+
+func newSQLLogger(opts SQLOptions) (*sql.DB, chan loggedEntry) {
+	logger, entries := newEntryChannelLogger("sql", 8)
+	return sql.OpenDB(WrapSQLConnector(fakeSQLConnector{}, logger, opts)), entries
+}
+
+func TestSQLExecIsLogged(t *testing.T) {
+	db, entries := newSQLLogger(SQLOptions{RedactArgs: []string{"$2", "password"}})
+	defer db.Close()
+
+	_, err := db.Exec("UPDATE users\n   SET name = ?, token = ?  WHERE id = ?", "bob", "secret", 7)
+	assert.NoError(t, err)
+	entry := <-entries
+	assert.Equal(t, DebugLevel, entry.level)
+	assert.Equal(t, "UPDATE users SET name = ?, token = ? WHERE id = ?", entry.msg)
+	assert.Equal(t, "exec", entry.data["op"])
+	assert.Equal(t, `"bob", REDACTED, 7`, entry.data["args"])
+	assert.Equal(t, int64(3), entry.data["rows"])
+	assert.Contains(t, entry.data, "duration")
+
+	_, err = db.Exec("UPDATE users SET password = @password", sql.Named("password", "hunter2"))
+	assert.NoError(t, err)
+	assert.Equal(t, "password=REDACTED", (<-entries).data["args"])
+}
+
+func TestSQLErrorsAndSlowQueries(t *testing.T) {
+	db, entries := newSQLLogger(SQLOptions{SlowQuery: 10 * time.Millisecond, HideArgs: true})
+	defer db.Close()
+
+	_, err := db.Exec("fail", 1)
+	assert.Error(t, err)
+	entry := <-entries
+	assert.Equal(t, ErrorLevel, entry.level)
+	assert.EqualError(t, entry.data[ErrorKey].(error), "syntax error")
+	assert.NotContains(t, entry.data, "args")
+
+	var n int
+	assert.NoError(t, db.QueryRow("SELECT sleep()").Scan(&n))
+	entry = <-entries
+	assert.Equal(t, WarnLevel, entry.level)
+	assert.Equal(t, "query", entry.data["op"])
+	assert.Equal(t, true, entry.data["slow"])
+}
+
+func TestSQLTransactionsAreLogged(t *testing.T) {
+	db, entries := newSQLLogger(SQLOptions{})
+	defer db.Close()
+
+	ctx := ContextWithEntry(context.Background(), db.Driver().(*sqlDriver).l.logger.WithField("request_id", "abc"))
+	tx, err := db.BeginTx(ctx, nil)
+	assert.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "DELETE FROM users")
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+
+	exec := <-entries
+	assert.Equal(t, "DELETE FROM users", exec.msg)
+	assert.Equal(t, "abc", exec.data["request_id"])
+
+	commit := <-entries
+	assert.Equal(t, "COMMIT", commit.msg)
+	assert.Equal(t, "commit", commit.data["op"])
+	assert.Equal(t, "abc", commit.data["request_id"])
+}
+
+type userID int
+
+func (id userID) Value() (driver.Value, error) {
+	return fmt.Sprintf("user-%d", id), nil
+}
+
+func TestSQLColumnConverters(t *testing.T) {
+	db, entries := newSQLLogger(SQLOptions{})
+	defer db.Close()
+
+	stmt, err := db.Prepare("UPPER ? ?")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer stmt.Close()
+
+	// The Valuer is called before the converter.
+	if _, err = stmt.Exec("bob", userID(7)); assert.NoError(t, err) {
+		assert.Equal(t, `"BOB", "USER-7"`, (<-entries).data["args"])
+	}
+
+	// Statements taking any number of arguments are not converted.
+	stmt, err = db.Prepare("SELECT ?")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer stmt.Close()
+	if _, err = stmt.Exec("bob"); assert.NoError(t, err) {
+		assert.Equal(t, `"bob"`, (<-entries).data["args"])
+	}
+}
+
+func TestFormatSQLArg(t *testing.T) {
+	assert.Equal(t, "NULL", formatSQLArg(nil))
+	assert.Equal(t, "[4 bytes]", formatSQLArg([]byte("abcd")))
+	assert.Equal(t, `"`+strings.Repeat("x", maxSQLArgSize)+`..."`, formatSQLArg(strings.Repeat("x", 100)))
+	assert.Equal(t, `"xx`+strings.Repeat("€", 20)+`..."`, formatSQLArg("xx"+strings.Repeat("€", 40)))
+	assert.Equal(t, "2.5", formatSQLArg(2.5))
+}
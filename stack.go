@@ -0,0 +1,104 @@
+package zlog
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+const maximumStackDepth = 64
+
+// Defines the key of the stack trace captured for entries, see
+// `Logger.ReportStackTrace`.
+var StackTraceKey = "stack"
+
+// A StackFrame is a single call of a stack trace.
+type StackFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+func (frame StackFrame) String() string {
+	return fmt.Sprintf("%s (%s:%d)", frame.Function, frame.File, frame.Line)
+}
+
+// StackTrace is stored as a field of the entries which captured one, the
+// innermost call first.
+type StackTrace []StackFrame
+
+func (st StackTrace) String() string {
+	lines := make([]string, len(st))
+	for i, frame := range st {
+		lines[i] = frame.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// StackFilter leaves frames nobody wants to read out of stack traces.
+type StackFilter struct {
+	// Hide the frames of the Go runtime.
+	HideRuntime bool
+	// Hide the frames of vendored packages and of modules from the module
+	// cache, i.e. code which is not part of the program itself.
+	HideVendor bool
+	// Hide the frames of these packages, matched as import path prefixes.
+	HidePackages []string
+}
+
+func (filter StackFilter) hides(frame runtime.Frame) bool {
+	pkg := packageName(frame.Function)
+	if filter.HideRuntime && (pkg == "runtime" || strings.HasPrefix(pkg, "runtime/")) {
+		return true
+	}
+	if filter.HideVendor && (strings.Contains(frame.File, "/vendor/") || strings.Contains(frame.File, "/pkg/mod/")) {
+		return true
+	}
+	for _, prefix := range filter.HidePackages {
+		if strings.HasPrefix(pkg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// captureStackTrace returns the stack of the current goroutine starting at
+// the first frame outside of zlog, skipping skip more frames above it.
+func captureStackTrace(skip int, filter StackFilter) StackTrace {
+	pcs := make([]uintptr, maximumStackDepth)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var st StackTrace
+	found := false
+	for {
+		frame, more := frames.Next()
+		if !found && !isInternalFrame(frame) {
+			found = true
+		}
+		if found {
+			if skip > 0 {
+				skip--
+			} else if !filter.hides(frame) {
+				st = append(st, StackFrame{Function: frame.Function, File: frame.File, Line: frame.Line})
+			}
+		}
+		if !more {
+			return st
+		}
+	}
+}
+
+// writeStackTrace prints the frames the way Go prints panics, indented below
+// the field name.
+func writeStackTrace(b *bytes.Buffer, st StackTrace, color Color) {
+	prefix := "         "
+	for _, frame := range st {
+		if color == nocolor {
+			fmt.Fprintf(b, "\n%s%s\n%s    %s:%d", prefix, frame.Function, prefix, frame.File, frame.Line)
+		} else {
+			fmt.Fprintf(b, "\n%s\x1b[%sm%s\n%s    %s:%d\x1b[0m", prefix, color, frame.Function, prefix, frame.File, frame.Line)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package zlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newStackLogger() (*Logger, *[]*Entry) {
+	var entries []*Entry
+	logger := New("stack")
+	logger.ReportStackTrace = true
+	logger.StackTraceLevel = ErrorLevel
+	logger.Formatter = formatterFunc(func(input FormatterInput) {
+		entry := *input.(*Entry)
+		entries = append(entries, &entry)
+	})
+	return logger, &entries
+}
+
+func TestStackTraceAtLevel(t *testing.T) {
+	logger, entries := newStackLogger()
+
+	logger.Warn("no stack")
+	logger.WithField("a", 1).Error("stack")
+	if !assert.Len(t, *entries, 2) {
+		return
+	}
+
+	assert.NotContains(t, (*entries)[0].Data, StackTraceKey)
+	st, ok := (*entries)[1].Data[StackTraceKey].(StackTrace)
+	if assert.True(t, ok) && assert.NotEmpty(t, st) {
+		assert.True(t, strings.HasSuffix(st[0].Function, "TestStackTraceAtLevel"))
+		assert.True(t, strings.HasSuffix(st[0].File, "stack_test.go"))
+	}
+}
+
+func TestStackTraceDoesNotLeakIntoParentEntry(t *testing.T) {
+	logger, _ := newStackLogger()
+
+	entry := logger.WithField("a", 1)
+	entry.Error("stack")
+	assert.NotContains(t, entry.Data, StackTraceKey)
+}
+
+func TestStackTraceFilter(t *testing.T) {
+	logger, entries := newStackLogger()
+	logger.StackTraceFilter = StackFilter{HideRuntime: true, HidePackages: []string{"testing"}}
+
+	logger.Error("filtered")
+	st := (*entries)[0].Data[StackTraceKey].(StackTrace)
+	assert.Len(t, st, 1)
+	for _, frame := range st {
+		assert.False(t, strings.HasPrefix(frame.Function, "runtime."), frame.Function)
+		assert.False(t, strings.HasPrefix(frame.Function, "testing."), frame.Function)
+	}
+}
+
+func TestStackTraceRendering(t *testing.T) {
+	logger, entries := newStackLogger()
+	logger.Error("rendered")
+	entry := (*entries)[0]
+
+	b, err := (&TextFormatter{DisableColors: true}).Format(entry)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "- stack    =\n         github.com/ssor/zlog.TestStackTraceRendering\n")
+
+	b, err = (&SeverityFormatter{}).Format(entry)
+	assert.NoError(t, err)
+	var fields struct {
+		Stack []map[string]interface{} `json:"stack"`
+	}
+	assert.NoError(t, json.NewDecoder(bytes.NewReader(b)).Decode(&fields))
+	if assert.NotEmpty(t, fields.Stack) {
+		assert.Equal(t, "github.com/ssor/zlog.TestStackTraceRendering", fields.Stack[0]["function"])
+		assert.Contains(t, fields.Stack[0], "line")
+	}
+}
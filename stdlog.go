@@ -0,0 +1,210 @@
+package zlog
+
+import (
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	stdLogMu       sync.Mutex
+	stdLogRedirect *stdLogWriter
+)
+
+// RedirectStdLog makes the standard library's `log` package, which many third
+// party libraries print with, write through logger at level. The prefix,
+// date and time added by `log` are removed from the messages, the file:line
+// added by `log.Lshortfile` or `log.Llongfile` becomes the caller of the
+// entries. The returned function restores the previous output.
+//
+// The flags and prefix are read when redirecting, change them before or
+// with SetPrintLineNumber.
+func RedirectStdLog(logger *Logger, level Level) func() {
+	stdLogMu.Lock()
+	defer stdLogMu.Unlock()
+
+	previous := log.Writer()
+	w := &stdLogWriter{
+		logger: logger,
+		level:  level,
+		flags:  log.Flags(),
+		prefix: log.Prefix(),
+	}
+	stdLogRedirect = w
+	log.SetOutput(w)
+
+	return func() {
+		stdLogMu.Lock()
+		defer stdLogMu.Unlock()
+
+		log.SetOutput(previous)
+		if stdLogRedirect == w {
+			stdLogRedirect = nil
+		}
+	}
+}
+
+func setStdLogFlags(flags int) {
+	stdLogMu.Lock()
+	defer stdLogMu.Unlock()
+
+	log.SetFlags(flags)
+	if stdLogRedirect != nil {
+		stdLogRedirect.setFlags(flags)
+	}
+}
+
+type stdLogWriter struct {
+	logger *Logger
+	level  Level
+
+	mu     sync.Mutex
+	flags  int
+	prefix string
+}
+
+func (w *stdLogWriter) setFlags(flags int) {
+	w.mu.Lock()
+	w.flags = flags
+	w.mu.Unlock()
+}
+
+// `log` writes each message at once, there is no need to split lines.
+func (w *stdLogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	flags, prefix := w.flags, w.prefix
+	w.mu.Unlock()
+
+	msg, caller := parseStdLogLine(string(p), flags, prefix)
+	if w.logger.Level >= w.level {
+		entry := w.logger.newEntry()
+		e := *entry
+		e.Caller = caller
+		if caller == nil && w.logger.ReportCaller {
+			// Without a file:line printed, the caller is the one of
+			// the `log` function.
+			e.Caller = w.logger.getCallerSkip(0, "log")
+		}
+		e.log(w.level, msg)
+		w.logger.releaseEntry(entry)
+	}
+	return len(p), nil
+}
+
+// parseStdLogLine takes apart what `log` printed, in its order: prefix,
+// date, time, file:line, the prefix again when log.Lmsgprefix is set, and
+// the message.
+func parseStdLogLine(line string, flags int, prefix string) (string, *runtime.Frame) {
+	line = strings.TrimSuffix(line, "\n")
+	if flags&log.Lmsgprefix == 0 {
+		line = strings.TrimPrefix(line, prefix)
+	}
+	if flags&log.Ldate != 0 && len(line) >= len("2006/01/02 ") {
+		line = line[len("2006/01/02 "):]
+	}
+	if flags&(log.Ltime|log.Lmicroseconds) != 0 {
+		n := len("15:04:05 ")
+		if flags&log.Lmicroseconds != 0 {
+			n = len("15:04:05.000000 ")
+		}
+		if len(line) >= n {
+			line = line[n:]
+		}
+	}
+
+	var caller *runtime.Frame
+	if flags&(log.Lshortfile|log.Llongfile) != 0 {
+		if end := strings.Index(line, ": "); end >= 0 {
+			location := line[:end]
+			if colon := strings.LastIndex(location, ":"); colon >= 0 {
+				if n, err := strconv.Atoi(location[colon+1:]); err == nil {
+					caller = &runtime.Frame{File: location[:colon], Line: n}
+					line = line[end+2:]
+				}
+			}
+		}
+	}
+
+	if flags&log.Lmsgprefix != 0 {
+		line = strings.TrimPrefix(line, prefix)
+	}
+	return line, caller
+}
+
+// CaptureStdout replaces os.Stdout with a pipe whose lines are logged through
+// logger at level, for code printing with fmt. On unix the file descriptor 1
+// itself is redirected, so that C code and child processes inheriting it are
+// captured too. The returned function puts the original file back once
+// everything written has been logged.
+//
+// Loggers writing to os.Stdout keep writing to the original file while it is
+// captured, loggers created meanwhile must not write to it.
+func CaptureStdout(logger *Logger, level Level) (func(), error) {
+	return captureFile(&os.Stdout, 1, "stdout", logger, level)
+}
+
+// CaptureStderr is CaptureStdout for os.Stderr, which is also where loggers
+// write by default.
+func CaptureStderr(logger *Logger, level Level) (func(), error) {
+	return captureFile(&os.Stderr, 2, "stderr", logger, level)
+}
+
+func captureFile(file **os.File, fd int, stream string, logger *Logger, level Level) (func(), error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	original := *file
+	saved, restoreFd, err := redirectFd(fd, writer)
+	if err != nil {
+		reader.Close()
+		writer.Close()
+		return nil, err
+	}
+
+	// Loggers writing to the redirected descriptor would log their own lines
+	// again.
+	var moved []*Logger
+	if saved != nil {
+		moved = replaceOutput(append(registeredLoggers(), logger), original, saved)
+	}
+	*file = writer
+	done := logger.WithField("stream", stream).readerLevel(reader, level)
+
+	return func() {
+		*file = original
+		restoreFd()
+		replaceOutput(moved, saved, original)
+		writer.Close()
+		<-done
+		if saved != nil {
+			saved.Close()
+		}
+	}, nil
+}
+
+func registeredLoggers() []*Logger {
+	loggersMu.Lock()
+	defer loggersMu.Unlock()
+	return append([]*Logger(nil), loggers...)
+}
+
+// replaceOutput makes the loggers writing to from write to to, and returns
+// them.
+func replaceOutput(loggers []*Logger, from, to io.Writer) []*Logger {
+	var replaced []*Logger
+	for _, logger := range loggers {
+		root := logger.root()
+		root.mu.Lock()
+		if logger.Out == from {
+			logger.Out = to
+			replaced = append(replaced, logger)
+		}
+		root.mu.Unlock()
+	}
+	return replaced
+}
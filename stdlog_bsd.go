@@ -0,0 +1,11 @@
+//go:build (darwin || freebsd || openbsd || netbsd || dragonfly) && !appengine
+// +build darwin freebsd openbsd netbsd dragonfly
+// +build !appengine
+
+package zlog
+
+import "syscall"
+
+func dup2(oldfd, newfd int) error {
+	return syscall.Dup2(oldfd, newfd)
+}
@@ -0,0 +1,11 @@
+//go:build !appengine
+// +build !appengine
+
+package zlog
+
+import "syscall"
+
+// Dup3 is the only one of the dup2 calls all the architectures have.
+func dup2(oldfd, newfd int) error {
+	return syscall.Dup3(oldfd, newfd, 0)
+}
@@ -0,0 +1,145 @@
+package zlog
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStdLogLine(t *testing.T) {
+	for _, c := range []struct {
+		line   string
+		flags  int
+		prefix string
+		msg    string
+		caller *runtime.Frame
+	}{
+		{"2009/01/23 01:23:23 hello\n", log.LstdFlags, "", "hello", nil},
+		{"[db] 2009/01/23 01:23:23.123123 main.go:23: hello: world\n", log.Ldate | log.Lmicroseconds | log.Lshortfile, "[db] ", "hello: world", &runtime.Frame{File: "main.go", Line: 23}},
+		{"01:23:23 /src/app/main.go:7: [db] hello\n", log.Ltime | log.Llongfile | log.Lmsgprefix, "[db] ", "hello", &runtime.Frame{File: "/src/app/main.go", Line: 7}},
+		{"plain\n", 0, "", "plain", nil},
+	} {
+		msg, caller := parseStdLogLine(c.line, c.flags, c.prefix)
+		assert.Equal(t, c.msg, msg, c.line)
+		assert.Equal(t, c.caller, caller, c.line)
+	}
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	defer log.SetFlags(log.Flags())
+	defer log.SetPrefix(log.Prefix())
+	log.SetPrefix("[lib] ")
+
+	var input FormatterInput
+	logger := New("stdlog")
+	logger.Formatter = formatterFunc(func(entry FormatterInput) {
+		input = entry
+	})
+
+	restore := RedirectStdLog(logger, WarnLevel)
+	SetPrintLineNumber(true)
+	_, _, line, _ := runtime.Caller(0)
+	log.Printf("from %s", "log")
+	restore()
+
+	if assert.NotNil(t, input) {
+		assert.Equal(t, WarnLevel, input.GetLevel())
+		assert.Equal(t, "from log", input.GetMessage())
+		assert.Equal(t, &runtime.Frame{File: "stdlog_test.go", Line: line + 1}, input.GetCaller())
+	}
+
+	assert.Equal(t, os.Stderr, log.Writer())
+}
+
+func TestRedirectStdLogReportsCaller(t *testing.T) {
+	defer log.SetFlags(log.Flags())
+
+	var input FormatterInput
+	logger := New("stdlog")
+	logger.ReportCaller = true
+	logger.Formatter = formatterFunc(func(entry FormatterInput) {
+		input = entry
+	})
+
+	log.SetFlags(log.LstdFlags)
+	restore := RedirectStdLog(logger, InfoLevel)
+	line := currentLine() + 1
+	log.Print("no file:line")
+	restore()
+
+	if assert.NotNil(t, input.GetCaller()) {
+		assert.Equal(t, line, input.GetCaller().Line)
+		assert.True(t, strings.HasSuffix(input.GetCaller().Function, "TestRedirectStdLogReportsCaller"))
+	}
+}
+
+func TestCaptureStdout(t *testing.T) {
+	logger, entries := newEntryChannelLogger("stdlog", 2)
+
+	restore, err := CaptureStdout(logger, InfoLevel)
+	if !assert.NoError(t, err) {
+		return
+	}
+	fmt.Println("printed")
+	fmt.Print("no newline")
+	restore()
+	close(entries)
+
+	var msgs []string
+	for entry := range entries {
+		assert.Equal(t, "stdout", entry.data["stream"])
+		msgs = append(msgs, entry.msg)
+	}
+	assert.Equal(t, []string{"printed", "no newline"}, msgs)
+}
+
+func TestCaptureStdoutDescriptor(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("only os.Stdout is captured on windows")
+	}
+	logger, entries := newEntryChannelLogger("stdlog", 1)
+	fd1 := os.Stdout
+
+	restore, err := CaptureStdout(logger, InfoLevel)
+	if !assert.NoError(t, err) {
+		return
+	}
+	fmt.Fprintln(fd1, "written to fd 1")
+	restore()
+	close(entries)
+
+	var msgs []string
+	for entry := range entries {
+		msgs = append(msgs, entry.msg)
+	}
+	assert.Equal(t, []string{"written to fd 1"}, msgs)
+}
+
+func TestCaptureStderrKeepsLoggersOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("only os.Stderr is captured on windows")
+	}
+	logger, entries := newEntryChannelLogger("stdlog", 1)
+	logger.Out = os.Stderr
+
+	restore, err := CaptureStderr(logger, InfoLevel)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotEqual(t, os.Stderr, logger.Out)
+	fmt.Fprintln(os.Stderr, "captured")
+	restore()
+	close(entries)
+
+	assert.Equal(t, os.Stderr, logger.Out)
+	var msgs []string
+	for entry := range entries {
+		msgs = append(msgs, entry.msg)
+	}
+	assert.Equal(t, []string{"captured"}, msgs)
+}
@@ -0,0 +1,28 @@
+//go:build (linux || darwin || freebsd || openbsd || netbsd || dragonfly) && !appengine
+// +build linux darwin freebsd openbsd netbsd dragonfly
+// +build !appengine
+
+package zlog
+
+import (
+	"os"
+	"syscall"
+)
+
+// redirectFd makes the file descriptor fd refer to file, so that what writes
+// to it without going through os.Stdout or os.Stderr, like C code and child
+// processes, is captured too. It returns a file still referring to what fd
+// did, and a function making fd refer to it again.
+func redirectFd(fd int, file *os.File) (*os.File, func(), error) {
+	saved, err := syscall.Dup(fd)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := dup2(int(file.Fd()), fd); err != nil {
+		syscall.Close(saved)
+		return nil, nil, err
+	}
+	return os.NewFile(uintptr(saved), file.Name()), func() {
+		dup2(saved, fd)
+	}, nil
+}
@@ -0,0 +1,494 @@
+package zlog
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+	"unsafe"
+)
+
+const defaultStructMaxDepth = 10
+
+// structOptions are the settings of the struct encoder, taken from the
+// logger.
+type structOptions struct {
+	maxDepth   int
+	unexported bool
+}
+
+func (logger *Logger) structOptions() structOptions {
+	if logger == nil {
+		return structOptions{}
+	}
+	return structOptions{maxDepth: logger.StructMaxDepth, unexported: logger.StructUnexported}
+}
+
+// encodeStruct renders value as JSON for WithStruct. Unlike encoding/json it
+// shows the fields tagged `json:"-"`, which are often what a debug log is
+// after, and survives cycles. Fields are configured with `zlog` tags, and
+// keep the name of their `json` tag when not given one:
+//
+//	Password string `zlog:"-"`           // left out
+//	Token    string `zlog:"token,redact"` // logged as REDACTED
+//	Comment  string `zlog:",omitempty"`   // left out when empty
+//
+// `zlog:"redact"` and `zlog:"omitempty"` are options, not names. Values
+// implementing json.Marshaler or encoding.TextMarshaler are logged as they
+// marshal themselves. Times, durations, errors and Stringers are logged as
+// strings, byte slices as text or base64.
+func encodeStruct(value interface{}, opts structOptions) []byte {
+	if opts.maxDepth <= 0 {
+		opts.maxDepth = defaultStructMaxDepth
+	}
+	e := &structEncoder{structOptions: opts, visiting: make(map[visit]bool)}
+	e.encode(addressable(reflect.ValueOf(value)), 0)
+	return e.b.Bytes()
+}
+
+type structEncoder struct {
+	structOptions
+	b bytes.Buffer
+	// Pointers, maps and slices being encoded, seeing one again is a cycle.
+	visiting map[visit]bool
+}
+
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// addressable copies v when it is not addressable, so that the methods of its
+// pointer and its unexported fields can be reached.
+func addressable(v reflect.Value) reflect.Value {
+	if !v.IsValid() || v.CanAddr() {
+		return v
+	}
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	return c
+}
+
+func (e *structEncoder) encode(v reflect.Value, depth int) {
+	if !v.IsValid() {
+		e.b.WriteString("null")
+		return
+	}
+	if e.marshal(v) {
+		return
+	}
+	if s, ok := specialString(v); ok {
+		e.writeString(s)
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		// Byte slices are shown as strings.
+		if depth >= e.maxDepth && !(v.Kind() != reflect.Struct && v.Type().Elem().Kind() == reflect.Uint8) {
+			e.writeString("<max depth>")
+			return
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		e.b.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.b.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.b.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		s := strconv.FormatFloat(f, 'g', -1, v.Type().Bits())
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			e.writeString(s)
+		} else {
+			e.b.WriteString(s)
+		}
+	case reflect.Complex64, reflect.Complex128:
+		e.writeString(fmt.Sprint(v.Complex()))
+	case reflect.String:
+		e.writeString(v.String())
+	case reflect.Interface:
+		if v.IsNil() {
+			e.b.WriteString("null")
+			return
+		}
+		e.encode(addressable(v.Elem()), depth)
+	case reflect.Ptr:
+		if v.IsNil() {
+			e.b.WriteString("null")
+			return
+		}
+		e.visit(v, func() { e.encode(v.Elem(), depth) })
+	case reflect.Map:
+		if v.IsNil() {
+			e.b.WriteString("null")
+			return
+		}
+		e.visit(v, func() { e.encodeMap(v, depth+1) })
+	case reflect.Slice:
+		if v.IsNil() {
+			e.b.WriteString("null")
+			return
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.writeString(bytesString(v.Bytes()))
+			return
+		}
+		e.visit(v, func() { e.encodeList(v, depth+1) })
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			bs := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(bs), v)
+			e.writeString(bytesString(bs))
+			return
+		}
+		e.encodeList(v, depth+1)
+	case reflect.Struct:
+		e.b.WriteByte('{')
+		first := true
+		e.encodeFields(v, depth+1, &first)
+		e.b.WriteByte('}')
+	default:
+		// Channels, functions and unsafe pointers.
+		if v.IsNil() {
+			e.b.WriteString("null")
+			return
+		}
+		e.writeString(fmt.Sprintf("%s(%#x)", v.Type(), v.Pointer()))
+	}
+}
+
+func (e *structEncoder) visit(v reflect.Value, encode func()) {
+	// Empty slices all point to the same place.
+	if v.Kind() == reflect.Slice && v.Len() == 0 {
+		encode()
+		return
+	}
+	key := visit{v.Pointer(), v.Type()}
+	if e.visiting[key] {
+		e.writeString("<cycle>")
+		return
+	}
+	e.visiting[key] = true
+	encode()
+	delete(e.visiting, key)
+}
+
+func (e *structEncoder) encodeList(v reflect.Value, depth int) {
+	e.b.WriteByte('[')
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			e.b.WriteByte(',')
+		}
+		e.encode(v.Index(i), depth)
+	}
+	e.b.WriteByte(']')
+}
+
+func (e *structEncoder) encodeMap(v reflect.Value, depth int) {
+	keys := v.MapKeys()
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = mapKeyString(addressable(k))
+	}
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return names[order[i]] < names[order[j]] })
+
+	e.b.WriteByte('{')
+	for n, i := range order {
+		if n > 0 {
+			e.b.WriteByte(',')
+		}
+		e.writeString(names[i])
+		e.b.WriteByte(':')
+		e.encode(addressable(v.MapIndex(keys[i])), depth)
+	}
+	e.b.WriteByte('}')
+}
+
+// encodeFields writes the fields of the struct v, those of embedded structs
+// are promoted like encoding/json does.
+func (e *structEncoder) encodeFields(v reflect.Value, depth int, first *bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := parseStructTag(sf)
+		if tag.skip {
+			continue
+		}
+		f := v.Field(i)
+		// The exported fields of embedded unexported structs are promoted
+		// without reading the unexported ones.
+		if sf.PkgPath != "" && e.unexported {
+			f = reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+		}
+
+		if sf.Anonymous && !tag.named && !tag.redact {
+			embedded := f
+			if embedded.Kind() == reflect.Ptr && !embedded.IsNil() {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if _, special := specialString(embedded); !special {
+					e.encodeFields(embedded, depth, first)
+					continue
+				}
+			}
+		}
+		if sf.PkgPath != "" && !e.unexported {
+			continue
+		}
+		if tag.omitEmpty && isEmptyValue(f) {
+			continue
+		}
+
+		if !*first {
+			e.b.WriteByte(',')
+		}
+		*first = false
+		e.writeString(tag.name)
+		e.b.WriteByte(':')
+		if tag.redact {
+			e.writeString(redactedValue)
+		} else {
+			e.encode(f, depth)
+		}
+	}
+}
+
+type structTag struct {
+	name      string
+	named     bool
+	skip      bool
+	omitEmpty bool
+	redact    bool
+}
+
+func parseStructTag(sf reflect.StructField) structTag {
+	tag := structTag{name: sf.Name}
+	if name := strings.Split(sf.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		tag.name, tag.named = name, true
+	}
+	zlogTag, ok := sf.Tag.Lookup("zlog")
+	if !ok {
+		return tag
+	}
+	if zlogTag == "-" {
+		tag.skip = true
+		return tag
+	}
+
+	options := strings.Split(zlogTag, ",")
+	if name := options[0]; name != "redact" && name != "omitempty" {
+		options = options[1:]
+		if name != "" {
+			tag.name, tag.named = name, true
+		}
+	}
+	for _, option := range options {
+		switch option {
+		case "omitempty":
+			tag.omitEmpty = true
+		case "redact":
+			tag.redact = true
+		}
+	}
+	return tag
+}
+
+// marshal writes the values implementing json.Marshaler or
+// encoding.TextMarshaler, and tells whether v was one.
+func (e *structEncoder) marshal(v reflect.Value) bool {
+	value, ok := methodsOf(v)
+	if !ok {
+		return false
+	}
+	switch m := value.(type) {
+	case json.Marshaler:
+		var raw []byte
+		err := safeCall(func() (err error) {
+			raw, err = m.MarshalJSON()
+			return err
+		})
+		if err == nil {
+			err = json.Compact(&e.b, raw)
+		}
+		if err != nil {
+			e.writeString(fmt.Sprintf("<error: %v>", err))
+		}
+		return true
+	case encoding.TextMarshaler:
+		var text []byte
+		err := safeCall(func() (err error) {
+			text, err = m.MarshalText()
+			return err
+		})
+		if err != nil {
+			e.writeString(fmt.Sprintf("<error: %v>", err))
+		} else {
+			e.writeString(string(text))
+		}
+		return true
+	}
+	return false
+}
+
+// methodsOf returns v, or its pointer when only the pointer has methods, as
+// an interface value to look the marshalers up. Nil values and the values of
+// unexported fields have none.
+func methodsOf(v reflect.Value) (interface{}, bool) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		if v.IsNil() {
+			return nil, false
+		}
+	}
+	if !v.CanInterface() {
+		return nil, false
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() {
+		if _, ok := v.Interface().(json.Marshaler); !ok {
+			if _, ok := v.Interface().(encoding.TextMarshaler); !ok {
+				return v.Addr().Interface(), true
+			}
+		}
+	}
+	return v.Interface(), true
+}
+
+func safeCall(f func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return f()
+}
+
+// specialString renders the types which are better read as a string than as
+// their fields.
+func specialString(v reflect.Value) (string, bool) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		if v.IsNil() {
+			return "", false
+		}
+	}
+	if !v.CanInterface() {
+		return "", false
+	}
+	if s, ok := stringOf(v.Interface()); ok {
+		return s, true
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() {
+		return stringOf(v.Addr().Interface())
+	}
+	return "", false
+}
+
+func stringOf(value interface{}) (string, bool) {
+	switch x := value.(type) {
+	case time.Time:
+		return x.Format(time.RFC3339Nano), true
+	case time.Duration:
+		return x.String(), true
+	case error:
+		return safeString(x.Error), true
+	case fmt.Stringer:
+		return safeString(x.String), true
+	}
+	return "", false
+}
+
+func safeString(f func() string) (s string) {
+	defer func() {
+		if p := recover(); p != nil {
+			s = fmt.Sprintf("<panic: %v>", p)
+		}
+	}()
+	return f()
+}
+
+func mapKeyString(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	if s, ok := specialString(k); ok {
+		return s
+	}
+	return fmt.Sprint(k.Interface())
+}
+
+// bytesString shows printable bytes as text, and the others in base64.
+func bytesString(bs []byte) string {
+	if !utf8.Valid(bs) {
+		return "base64:" + base64.StdEncoding.EncodeToString(bs)
+	}
+	for _, r := range string(bs) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return "base64:" + base64.StdEncoding.EncodeToString(bs)
+		}
+	}
+	return string(bs)
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	case reflect.Struct:
+		return v.CanInterface() && reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+	}
+	return false
+}
+
+// writeString writes s as a JSON string. Unlike encoding/json it does not
+// escape HTML characters, which would be hard to read in the text output.
+func (e *structEncoder) writeString(s string) {
+	e.b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			e.b.WriteByte('\\')
+			e.b.WriteRune(r)
+		case '\n':
+			e.b.WriteString(`\n`)
+		case '\r':
+			e.b.WriteString(`\r`)
+		case '\t':
+			e.b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&e.b, `\u%04x`, r)
+			} else {
+				e.b.WriteRune(r)
+			}
+		}
+	}
+	e.b.WriteByte('"')
+}
@@ -0,0 +1,136 @@
+package zlog
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type structLevel int
+
+func (l structLevel) String() string { return []string{"low", "high"}[l] }
+
+type structBase struct {
+	ID int `json:"id"`
+}
+
+type structOrder struct {
+	structBase
+	Customer string        `json:"customer"`
+	Internal string        `json:"-"`
+	Secret   string        `zlog:"-"`
+	Card     string        `zlog:"card,redact"`
+	Note     string        `zlog:",omitempty"`
+	Created  time.Time     `zlog:"created"`
+	Timeout  time.Duration `zlog:"timeout"`
+	Err      error         `zlog:"err"`
+	Level    structLevel   `zlog:"level"`
+	Raw      []byte        `zlog:"raw"`
+	Blob     []byte        `zlog:"blob"`
+	Tags     map[string]int
+	Items    []string
+	Nothing  *int
+	private  string
+}
+
+func TestEncodeStruct(t *testing.T) {
+	order := structOrder{
+		structBase: structBase{ID: 7},
+		Customer:   "bob",
+		Internal:   "shown",
+		Secret:     "hidden",
+		Card:       "4111",
+		Created:    time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Timeout:    1500 * time.Millisecond,
+		Err:        errors.New("failed"),
+		Level:      1,
+		Raw:        []byte("text <b>"),
+		Blob:       []byte{0, 1, 2},
+		Tags:       map[string]int{"b": 2, "a": 1},
+		private:    "unexported",
+	}
+	assert.Equal(t, `{"id":7,"customer":"bob","Internal":"shown","card":"REDACTED","created":"2020-01-02T03:04:05Z",`+
+		`"timeout":"1.5s","err":"failed","level":"high","raw":"text <b>","blob":"base64:AAEC","Tags":{"a":1,"b":2},`+
+		`"Items":null,"Nothing":null,"private":"unexported"}`, string(encodeStruct(&order, structOptions{unexported: true})))
+
+	// Unexported fields are only read when asked for.
+	exported := string(encodeStruct(&order, structOptions{}))
+	assert.Contains(t, exported, `{"id":7,"customer":"bob",`)
+	assert.NotContains(t, exported, "private")
+}
+
+type structNode struct {
+	Name string
+	Next *structNode
+}
+
+func TestEncodeStructCycles(t *testing.T) {
+	a := &structNode{Name: "a"}
+	a.Next = &structNode{Name: "b", Next: a}
+	assert.Equal(t, `{"Name":"a","Next":{"Name":"b","Next":"<cycle>"}}`, string(encodeStruct(a, structOptions{})))
+
+	// The same value twice is not a cycle.
+	shared := &structNode{Name: "shared"}
+	assert.Equal(t, `[{"Name":"shared","Next":null},{"Name":"shared","Next":null}]`, string(encodeStruct([]*structNode{shared, shared}, structOptions{})))
+
+	m := map[string]interface{}{}
+	m["self"] = m
+	assert.Equal(t, `{"self":"<cycle>"}`, string(encodeStruct(m, structOptions{})))
+}
+
+func TestEncodeStructMaxDepth(t *testing.T) {
+	nested := map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": 1}}}
+	assert.Equal(t, `{"a":{"b":"<max depth>"}}`, string(encodeStruct(nested, structOptions{maxDepth: 2})))
+
+	logger, logged := newRedactLogger()
+	logger.StructMaxDepth = 1
+	logger.WithStruct(nested).Info("nested")
+	assert.Equal(t, `{"a":"<max depth>"}`, string((*logged).GetJsonRaw()))
+}
+
+type structMarshalers struct {
+	Raw    json.RawMessage
+	Big    *big.Int
+	IP     net.IP
+	Level  Level
+	Failed failingMarshaler
+}
+
+type failingMarshaler struct{}
+
+func (failingMarshaler) MarshalJSON() ([]byte, error) { return nil, errors.New("no JSON") }
+
+func TestEncodeStructMarshalers(t *testing.T) {
+	v := structMarshalers{
+		Raw:   json.RawMessage(`{"a": [1, 2]}`),
+		Big:   new(big.Int).Lsh(big.NewInt(1), 70),
+		IP:    net.IPv4(10, 0, 0, 1),
+		Level: WarnLevel,
+	}
+	assert.Equal(t, `{"Raw":{"a":[1,2]},"Big":1180591620717411303424,"IP":"10.0.0.1","Level":"**** ",`+
+		`"Failed":"<error: no JSON>"}`, string(encodeStruct(v, structOptions{})))
+}
+
+func TestEncodeStructScalars(t *testing.T) {
+	assert.Equal(t, `null`, string(encodeStruct(nil, structOptions{})))
+	assert.Equal(t, `"a\"b\n"`, string(encodeStruct("a\"b\n", structOptions{})))
+	assert.Equal(t, `[1.5,"NaN",true]`, string(encodeStruct([]interface{}{1.5, nan(), true}, structOptions{})))
+	assert.Equal(t, `{"1":"x","2":"y"}`, string(encodeStruct(map[int]string{2: "y", 1: "x"}, structOptions{})))
+}
+
+func nan() float64 {
+	zero := 0.0
+	return zero / zero
+}
+
+func TestWithStructIsValidJSON(t *testing.T) {
+	logger, logged := newRedactLogger()
+	logger.WithStruct(structOrder{Customer: "bob"}).Info("order")
+	assert.Contains(t, string((*logged).GetJsonRaw()), `"customer":"bob"`)
+	assert.NotContains(t, prettyJSON((*logged).GetJsonRaw()), "JSON parse error")
+}
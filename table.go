@@ -0,0 +1,225 @@
+package zlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	defaultTableCellWidth = 32
+	defaultTableMaxRows   = 20
+)
+
+// Table is the field logged by WithTable. TextFormatter prints it as an
+// aligned table, JSON formatters as an array of objects.
+type Table struct {
+	Columns []string
+	// The cells of each row, in the order of Columns. Cells missing from a
+	// row are nil.
+	Rows [][]interface{}
+}
+
+// newTable reads rows, a slice of structs or maps, through the struct
+// encoder of WithStruct. Without columns, all the fields and keys are shown
+// in the order they are first seen.
+func newTable(rows interface{}, columns []string, opts structOptions) Table {
+	v := reflect.ValueOf(rows)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	var items []interface{}
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, v.Index(i).Interface())
+		}
+	} else if v.IsValid() {
+		items = append(items, v.Interface())
+	}
+
+	t := Table{Columns: columns}
+	seen := make(map[string]bool)
+	cells := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		keys, values := decodeTableRow(encodeStruct(item, opts))
+		cells[i] = values
+		if columns != nil {
+			continue
+		}
+		for _, k := range keys {
+			if !seen[k] {
+				seen[k] = true
+				t.Columns = append(t.Columns, k)
+			}
+		}
+	}
+
+	t.Rows = make([][]interface{}, len(cells))
+	for i, values := range cells {
+		row := make([]interface{}, len(t.Columns))
+		for j, column := range t.Columns {
+			row[j] = values[column]
+		}
+		t.Rows[i] = row
+	}
+	return t
+}
+
+// decodeTableRow returns the keys of a JSON object in order, with their
+// values. Anything else is a single "value" column.
+func decodeTableRow(raw []byte) ([]string, map[string]interface{}) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if token, err := dec.Token(); err != nil || token != json.Delim('{') {
+		var v interface{}
+		dec = json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		dec.Decode(&v)
+		return []string{"value"}, map[string]interface{}{"value": v}
+	}
+
+	var keys []string
+	values := make(map[string]interface{})
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			break
+		}
+		key, _ := token.(string)
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			break
+		}
+		keys = append(keys, key)
+		values[key] = v
+	}
+	return keys, values
+}
+
+// MarshalJSON keeps the order of the columns in each object.
+func (t Table) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('[')
+	for i, row := range t.Rows {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('{')
+		for j, column := range t.Columns {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			key, _ := json.Marshal(column)
+			value, err := json.Marshal(row[j])
+			if err != nil {
+				return nil, err
+			}
+			b.Write(key)
+			b.WriteByte(':')
+			b.Write(value)
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(']')
+	return b.Bytes(), nil
+}
+
+func (t Table) String() string {
+	var b bytes.Buffer
+	writeTable(&b, t, "", defaultTableCellWidth, defaultTableMaxRows, nocolor)
+	return strings.TrimPrefix(b.String(), "\n")
+}
+
+func tableCell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	}
+	return diffValueString(v)
+}
+
+// writeTable prints at most maxRows rows, cells are cut at cellWidth
+// characters.
+func writeTable(b *bytes.Buffer, t Table, prefix string, cellWidth, maxRows int, color Color) {
+	if cellWidth <= 0 {
+		cellWidth = defaultTableCellWidth
+	}
+	if maxRows <= 0 {
+		maxRows = defaultTableMaxRows
+	}
+	rows := t.Rows
+	if len(rows) > maxRows {
+		rows = rows[:maxRows]
+	}
+
+	cells := make([][]string, len(rows)+1)
+	cells[0] = make([]string, len(t.Columns))
+	widths := make([]int, len(t.Columns))
+	for j, column := range t.Columns {
+		cells[0][j] = cutCell(column, cellWidth)
+	}
+	for i, row := range rows {
+		cells[i+1] = make([]string, len(t.Columns))
+		for j, v := range row {
+			cells[i+1][j] = cutCell(tableCell(v), cellWidth)
+		}
+	}
+	for _, row := range cells {
+		for j, cell := range row {
+			if n := displayWidth(cell); n > widths[j] {
+				widths[j] = n
+			}
+		}
+	}
+
+	line := func(s string) {
+		if color == nocolor {
+			fmt.Fprintf(b, "\n%s%s", prefix, s)
+		} else {
+			fmt.Fprintf(b, "\n%s\x1b[%sm%s\x1b[0m", prefix, color, s)
+		}
+	}
+	var border strings.Builder
+	border.WriteByte('+')
+	for _, w := range widths {
+		border.WriteString(strings.Repeat("-", w+2))
+		border.WriteByte('+')
+	}
+
+	line(border.String())
+	for i, row := range cells {
+		var s strings.Builder
+		s.WriteByte('|')
+		for j, cell := range row {
+			s.WriteString(" " + cell + strings.Repeat(" ", widths[j]-displayWidth(cell)) + " |")
+		}
+		line(s.String())
+		if i == 0 {
+			line(border.String())
+		}
+	}
+	line(border.String())
+	if more := len(t.Rows) - len(rows); more > 0 {
+		line(fmt.Sprintf("... %d more", more))
+	}
+}
+
+// cutCell keeps cells on one line and at most width columns.
+func cutCell(s string, width int) string {
+	s = strings.Replace(strings.Replace(s, "\r", `\r`, -1), "\n", `\n`, -1)
+	if displayWidth(s) <= width {
+		return s
+	}
+	if width <= 3 {
+		return headWidth(s, width)
+	}
+	return headWidth(s, width-3) + "..."
+}
@@ -0,0 +1,64 @@
+package zlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tableRow struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Admin bool   `json:"admin,omitempty" zlog:",omitempty"`
+}
+
+func TestNewTable(t *testing.T) {
+	table := newTable([]tableRow{{1, "bob", true}, {2, "alice", false}}, nil, structOptions{})
+	assert.Equal(t, []string{"id", "name", "admin"}, table.Columns)
+	assert.Equal(t, [][]interface{}{
+		{json.Number("1"), "bob", true},
+		{json.Number("2"), "alice", nil},
+	}, table.Rows)
+
+	table = newTable([]map[string]interface{}{{"b": 1, "a": "x"}, {"c": nil}}, []string{"c", "a"}, structOptions{})
+	assert.Equal(t, []string{"c", "a"}, table.Columns)
+	assert.Equal(t, [][]interface{}{{nil, "x"}, {nil, nil}}, table.Rows)
+
+	table = newTable([]int{1, 2}, nil, structOptions{})
+	assert.Equal(t, []string{"value"}, table.Columns)
+	assert.Len(t, table.Rows, 2)
+}
+
+func TestTableRendering(t *testing.T) {
+	table := newTable([]tableRow{{1, "bob", true}, {2, "a very long name indeed", false}, {3, "carol", false}}, []string{"id", "name"}, structOptions{})
+
+	var b bytes.Buffer
+	writeTable(&b, table, "  ", 10, 2, nocolor)
+	assert.Equal(t, strings.Join([]string{
+		"",
+		"  +----+------------+",
+		"  | id | name       |",
+		"  +----+------------+",
+		"  | 1  | bob        |",
+		"  | 2  | a very ... |",
+		"  +----+------------+",
+		"  ... 1 more",
+	}, "\n"), b.String())
+
+	serialized, err := json.Marshal(table)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"id":1,"name":"bob"},{"id":2,"name":"a very long name indeed"},{"id":3,"name":"carol"}]`, string(serialized))
+}
+
+func TestWithTable(t *testing.T) {
+	table := New("table").WithTable("users", []tableRow{{1, "bob", false}}).Data["users"]
+
+	assert.Contains(t, renderPlainField("users", table), "\n     - users    =\n         +----+------+\n         | id | name |")
+
+	b, err := json.Marshal(table)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"id":1,"name":"bob"}]`, string(b))
+}
@@ -0,0 +1,119 @@
+package zlog
+
+import (
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// outputTerminal is what is known of the writer a logger outputs to, found
+// once per writer.
+type outputTerminal struct {
+	file *os.File
+	// Whether file is a terminal.
+	isTerminal bool
+	// Whether entries are colored, from isTerminal and the environment.
+	colors bool
+
+	mu      sync.Mutex
+	columns int
+	resizes int32
+}
+
+func newOutputTerminal(out io.Writer) *outputTerminal {
+	t := &outputTerminal{}
+	if file, ok := out.(*os.File); ok && file != nil {
+		t.file = file
+		t.isTerminal = isTerminalFd(file.Fd())
+	}
+	// The console of Windows only takes escape sequences once asked to.
+	t.colors = colorsFromEnv(t.isTerminal && runtime.GOOS != "windows")
+	return t
+}
+
+// colorsFromEnv follows NO_COLOR, FORCE_COLOR and TERM=dumb, in this order,
+// before whether the output is a color terminal.
+func colorsFromEnv(isTerminal bool) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if force := os.Getenv("FORCE_COLOR"); force != "" && force != "0" && force != "false" {
+		return true
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal
+}
+
+// width returns the columns of the terminal, read again when it is resized,
+// or 0 when the output is not a terminal.
+func (t *outputTerminal) width() int {
+	if !t.isTerminal {
+		return 0
+	}
+	resizes, notified := resizeCount()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !notified || t.columns == 0 || t.resizes != resizes {
+		t.columns, _ = terminalWidth(t.file.Fd())
+		t.resizes = resizes
+	}
+	return t.columns
+}
+
+var (
+	resizeOnce     sync.Once
+	resizes        int32
+	resizeNotified bool
+)
+
+// resizeCount returns how many times the terminal was resized, and whether
+// resizes are notified at all.
+func resizeCount() (int32, bool) {
+	resizeOnce.Do(func() {
+		resizeNotified = notifyResize(func() {
+			atomic.AddInt32(&resizes, 1)
+		})
+	})
+	return atomic.LoadInt32(&resizes), resizeNotified
+}
+
+var stderrTerminal struct {
+	once sync.Once
+	t    *outputTerminal
+}
+
+// defaultOutputTerminal is used for entries not written by a logger.
+func defaultOutputTerminal() *outputTerminal {
+	stderrTerminal.once.Do(func() {
+		stderrTerminal.t = newOutputTerminal(os.Stderr)
+	})
+	return stderrTerminal.t
+}
+
+// outputTerminal returns what is known of Out, found again when Out is
+// changed to another file.
+func (logger *Logger) outputTerminal() *outputTerminal {
+	root := logger.root()
+	root.mu.Lock()
+	out := logger.Out
+	root.mu.Unlock()
+
+	file, _ := out.(*os.File)
+	if t, ok := logger.terminal.Load().(*outputTerminal); ok && t.file == file {
+		return t
+	}
+	t := newOutputTerminal(out)
+	logger.terminal.Store(t)
+	return t
+}
+
+func (entry *Entry) outputTerminal() *outputTerminal {
+	if entry.Logger == nil {
+		return defaultOutputTerminal()
+	}
+	return entry.Logger.outputTerminal()
+}
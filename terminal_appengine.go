@@ -6,3 +6,16 @@ package zlog
 func IsTerminal() bool {
 	return true
 }
+
+func isTerminalFd(fd uintptr) bool {
+	return true
+}
+
+// terminalWidth is unknown on appengine, the fixed layout is used.
+func terminalWidth(fd uintptr) (int, bool) {
+	return 0, false
+}
+
+func notifyResize(refresh func()) bool {
+	return false
+}
@@ -9,14 +9,40 @@
 package zlog
 
 import (
+	"os"
+	"os/signal"
 	"syscall"
 	"unsafe"
 )
 
 // IsTerminal returns true if stderr's file descriptor is a terminal.
 func IsTerminal() bool {
-	fd := syscall.Stderr
+	return isTerminalFd(uintptr(syscall.Stderr))
+}
+
+// isTerminalFd returns true if the file descriptor is a terminal.
+func isTerminalFd(fd uintptr) bool {
 	var termios Termios
-	_, _, err := syscall.Syscall6(syscall.SYS_IOCTL, uintptr(fd), ioctlReadTermios, uintptr(unsafe.Pointer(&termios)), 0, 0, 0)
+	_, _, err := syscall.Syscall6(syscall.SYS_IOCTL, fd, ioctlReadTermios, uintptr(unsafe.Pointer(&termios)), 0, 0, 0)
 	return err == 0
 }
+
+// terminalWidth returns the number of columns of the terminal on the file
+// descriptor.
+func terminalWidth(fd uintptr) (int, bool) {
+	var ws struct{ Row, Col, Xpixel, Ypixel uint16 }
+	_, _, err := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws)))
+	return int(ws.Col), err == 0 && ws.Col > 0
+}
+
+// notifyResize calls refresh each time the terminal is resized.
+func notifyResize(refresh func()) bool {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGWINCH)
+	go func() {
+		for range c {
+			refresh()
+		}
+	}()
+	return true
+}
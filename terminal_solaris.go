@@ -4,12 +4,39 @@ package zlog
 
 import (
 	"os"
+	"os/signal"
 
 	"golang.org/x/sys/unix"
 )
 
 // IsTerminal returns true if the given file descriptor is a terminal.
 func IsTerminal() bool {
-	_, err := unix.IoctlGetTermios(int(os.Stdout.Fd()), unix.TCGETA)
+	return isTerminalFd(os.Stdout.Fd())
+}
+
+func isTerminalFd(fd uintptr) bool {
+	_, err := unix.IoctlGetTermios(int(fd), unix.TCGETA)
 	return err == nil
 }
+
+// terminalWidth returns the number of columns of the terminal on the file
+// descriptor.
+func terminalWidth(fd uintptr) (int, bool) {
+	ws, err := unix.IoctlGetWinsize(int(fd), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 {
+		return 0, false
+	}
+	return int(ws.Col), true
+}
+
+// notifyResize calls refresh each time the terminal is resized.
+func notifyResize(refresh func()) bool {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, unix.SIGWINCH)
+	go func() {
+		for range c {
+			refresh()
+		}
+	}()
+	return true
+}
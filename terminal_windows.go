@@ -15,13 +15,44 @@ import (
 var kernel32 = syscall.NewLazyDLL("kernel32.dll")
 
 var (
-	procGetConsoleMode = kernel32.NewProc("GetConsoleMode")
+	procGetConsoleMode             = kernel32.NewProc("GetConsoleMode")
+	procGetConsoleScreenBufferInfo = kernel32.NewProc("GetConsoleScreenBufferInfo")
 )
 
 // IsTerminal returns true if stderr's file descriptor is a terminal.
 func IsTerminal() bool {
-	fd := syscall.Stderr
+	return isTerminalFd(uintptr(syscall.Stderr))
+}
+
+// isTerminalFd returns true if the handle is a console.
+func isTerminalFd(fd uintptr) bool {
 	var st uint32
-	r, _, e := syscall.Syscall(procGetConsoleMode.Addr(), 2, uintptr(fd), uintptr(unsafe.Pointer(&st)), 0)
+	r, _, e := syscall.Syscall(procGetConsoleMode.Addr(), 2, fd, uintptr(unsafe.Pointer(&st)), 0)
 	return r != 0 && e == 0
 }
+
+type consoleScreenBufferInfo struct {
+	size              [2]int16
+	cursorPosition    [2]int16
+	attributes        uint16
+	left, top         int16
+	right, bottom     int16
+	maximumWindowSize [2]int16
+}
+
+// terminalWidth returns the number of columns of the console window of the
+// handle.
+func terminalWidth(fd uintptr) (int, bool) {
+	var info consoleScreenBufferInfo
+	r, _, e := syscall.Syscall(procGetConsoleScreenBufferInfo.Addr(), 2, fd, uintptr(unsafe.Pointer(&info)), 0)
+	if r == 0 || e != 0 {
+		return 0, false
+	}
+	return int(info.right-info.left) + 1, true
+}
+
+// notifyResize reports that consoles send no resize signal, so the width is
+// read again for each entry.
+func notifyResize(refresh func()) bool {
+	return false
+}
@@ -11,22 +11,20 @@ import (
 )
 
 const (
-    nocolor = 0
-    red     = 31
-    green   = 32
-    yellow  = 33
-    blue    = 34
-    gray    = 37
+    nocolor Color = ""
+    red     Color = "31"
+    green   Color = "32"
+    yellow  Color = "33"
+    blue    Color = "34"
+    magenta Color = "35"
+    cyan    Color = "36"
+    gray    Color = "37"
 )
 
-var (
-    baseTimestamp time.Time
-    isTerminal    bool
-)
+var baseTimestamp time.Time
 
 func init() {
     baseTimestamp = time.Now()
-    isTerminal = IsTerminal()
 }
 
 func miniTS() int {
@@ -35,11 +33,29 @@ func miniTS() int {
 
 type TextFormatter struct {
     // Set to true to bypass checking for a TTY before outputting colors.
+    // Without it, colors are used when the `Out` of the logger is a terminal
+    // other than the Windows console, unless the NO_COLOR environment
+    // variable is set or TERM is "dumb". FORCE_COLOR enables them in any
+    // case.
     ForceColors bool
 
-    // Force disabling colors.
+    // Force disabling colors, over ForceColors and the environment.
     DisableColors bool
 
+    // Colors and level labels, see DefaultTheme(), DarkTheme() and
+    // LightTheme().
+    Theme Theme
+
+    // Give the module column of each module its own color, picked from
+    // Theme.ModulePalette by a hash of its name, so that it stays the same
+    // from one run to the next. Theme.Modules sets the colors of given
+    // modules, whether or not this is set.
+    ColorModules bool
+
+    // Pad the module column to this width so that the entries of several
+    // modules line up, longer names are cut to their end like "...b/reader".
+    ModuleWidth int
+
     // Disable timestamp logging. useful when output is redirected to logging
     // system that already adds timestamps.
     DisableTimestamp bool
@@ -55,34 +71,66 @@ type TextFormatter struct {
     // that log extremely frequently and don't use the JSON formatter this may not
     // be desired.
     DisableSorting bool
-}
 
-func dumpStacks() {
-
-    prefix := "      "
-    buf := make([]byte, 16384)
-    buf = buf[:runtime.Stack(buf, true)]
-    results := bytes.Split(buf, []byte{0xa})
-    //spew.Dump(results)
-    if len(results) > 6 {
-        fmt.Println(prefix + "=== BEGIN stack dump ===")
-        fmt.Println()
-        for i := 6; i < len(results); i += 1 {
-            if len(results[i]) <= 0 { //do not print all goroutine stacks
-                break
-            }
-            if i%2 == 0 { //just print code line
-                fmt.Printf("%s%s: %s\n", prefix, "- ", strings.TrimSpace(string(results[i])))
-            }
-        }
-        fmt.Println()
-        fmt.Println(prefix + "=== END   stack dump ===")
-    } else {
-        fmt.Println(prefix + "=== no stack to print ===")
-    }
+    // Print the source lines around the call site of error, fatal and panic
+    // entries, so the code can be read along with the log.
+    ShowSource bool
+
+    // Print the source lines around the call site of every entry logged by
+    // one of these modules, whatever its level.
+    SourceModules []string
+
+    // Number of lines printed before and after the call site, defaults to 2.
+    SourceContext int
+
+    // When colors are used, the caller is wrapped in an OSC 8 hyperlink built
+    // from this template, so terminals supporting it can open the code. The
+    // placeholders {file}, {relfile}, {line} and {commit} are substituted,
+    // e.g. "vscode://file/{file}:{line}" or
+    // "https://github.com/ssor/zlog/blob/{commit}/{relfile}#L{line}".
+    HyperlinkTemplate string
+
+    // Directory stripped from the file path to form {relfile}, usually the
+    // root of the repository.
+    HyperlinkRoot string
+
+    // Revision substituted for {commit}.
+    HyperlinkCommit string
+
+    // Width at which the cells of tables logged with WithTable are cut,
+    // defaults to 32 characters.
+    TableCellWidth int
+
+    // Rows of tables printed before "... N more", defaults to 20.
+    TableMaxRows int
+
+    // Bytes of the fields logged with Binary shown in the hex dump, defaults
+    // to 256.
+    BinaryMaxBytes int
+
+    // Columns the entries are laid out in: the module and time are aligned
+    // to the right edge, long messages wrap under their first line and field
+    // values are cut to fit. Defaults to the width of the terminal `Out` is,
+    // followed when it is resized. Outside a terminal, or when set to -1,
+    // messages are padded to 44 columns and field values cut at 128
+    // characters.
+    TerminalWidth int
 }
 
-func (f *TextFormatter) Format(entry FormatterInput, callDepth int) ([]byte, error) {
+const (
+    defaultMessageWidth = 44
+    defaultValueWidth   = 128
+    // Below these, the terminal is too narrow for its width to be followed.
+    minMessageWidth = 20
+    minValueWidth   = 32
+    // Keys are padded to this width.
+    fieldKeyWidth = 8
+    // Columns before the value of a colored field, "      - key      = ",
+    // the plain layout is one column narrower.
+    fieldPrefixWidth = len("      - ") + fieldKeyWidth + len(" = ")
+)
+
+func (f *TextFormatter) Format(entry FormatterInput) ([]byte, error) {
     var b *bytes.Buffer
     var keys = make([]string, 0, len(entry.GetData()))
     for k := range entry.GetData() {
@@ -100,45 +148,63 @@ func (f *TextFormatter) Format(entry FormatterInput, callDepth int) ([]byte, err
 
     prefixFieldClashes(entry.GetData())
 
-    isColorTerminal := isTerminal && (runtime.GOOS != "windows")
-    isColored := (f.ForceColors || isColorTerminal) && !f.DisableColors
+    term := defaultOutputTerminal()
+    if e, ok := entry.(interface{ outputTerminal() *outputTerminal }); ok {
+        term = e.outputTerminal()
+    }
+    isColored := (f.ForceColors || term.colors) && !f.DisableColors
 
     timestampFormat := f.TimestampFormat
     if timestampFormat == "" {
         timestampFormat = DefaultTimestampFormat
     }
-    //fileInfo := formatShortFile(loggerDefaultCallDepth)
-    //isColored = false
+    caller := entry.GetCaller()
+    var snippetFrom *runtime.Frame
+    if f.showSource(entry) {
+        snippetFrom = caller
+        if snippetFrom == nil {
+            if frame, ok := externalCaller(0); ok {
+                snippetFrom = &frame
+            }
+        }
+    }
+    width := f.layoutWidth(term)
     if isColored {
-        f.printColored(b, entry, keys, timestampFormat, callDepth)
+        f.printColored(b, entry, keys, timestampFormat, caller, width)
     } else {
-        f.printPlain(b, entry, keys, timestampFormat, callDepth)
+        f.printPlain(b, entry, keys, timestampFormat, caller, width)
+    }
+    if snippetFrom != nil {
+        color := nocolor
+        if isColored {
+            color = f.Theme.levelColor(entry.GetLevel())
+        }
+        writeSourceSnippet(b, snippetFrom.File, snippetFrom.Line, f.SourceContext, color)
     }
 
     b.WriteByte('\n')
     return b.Bytes(), nil
 }
 
-func (f *TextFormatter) printPlain(b *bytes.Buffer, entry FormatterInput, keys []string, timestampFormat string, callDepth int) {
+func (f *TextFormatter) printPlain(b *bytes.Buffer, entry FormatterInput, keys []string, timestampFormat string, caller *runtime.Frame, width int) {
     switch entry.GetLevel() {
     case DebugLevel:
     case WarnLevel:
     case ErrorLevel, FatalLevel, PanicLevel:
     default:
     }
-    codeSrc := entry.GetData()[moduleKey]
-    if callDepth > 0 {
-        codeSrc = formatShortFile(callDepth)
+    codeSrc, padding := f.moduleColumn(entry, caller)
+    right := fmt.Sprintf("  (%s%s)[%s]", codeSrc, padding, entry.GetTime().Format(timestampFormat))
+    if tag := goroutineTag(entry); tag != "" {
+        right += fmt.Sprintf(" [%s]", tag)
     }
-    fmt.Fprintf(b, "%s%-44s  (%s)[%s]", entry.GetLevel().String(), entry.GetMessage(), codeSrc, entry.GetTime().Format(timestampFormat))
+    b.WriteString(layoutHeader(f.Theme.label(entry.GetLevel()), entry.GetMessage(), right, width, nocolor))
 
     for _, key := range keys {
         if key == moduleKey {
             continue
         }
-        //f.appendKeyValue(b, key, )
-        value := fmt.Sprintf("%+v", entry.GetData()[key])
-        fmt.Fprintf(b, "\n     - %-8s = %+v", key, tripHeadAndTail(value, 128))
+        f.renderField(b, key, entry.GetData()[key], width, false)
     }
 
     jsonRaw := entry.GetJsonRaw()
@@ -147,65 +213,216 @@ func (f *TextFormatter) printPlain(b *bytes.Buffer, entry FormatterInput, keys [
     }
 }
 
-func formatShortFile(callDepth int) string {
-    _, file, line, ok := runtime.Caller(callDepth)
-    if !ok {
-        file = "???"
-        line = 0
-        return "???:0"
+func formatShortFile(caller *runtime.Frame) string {
+    return fmt.Sprintf("%s:%-3d", caller.File, caller.Line)
+}
+
+func (f *TextFormatter) showSource(entry FormatterInput) bool {
+    if f.ShowSource && entry.GetLevel() <= ErrorLevel {
+        return true
+    }
+    module := entry.GetData()[moduleKey]
+    for _, m := range f.SourceModules {
+        if m == module {
+            return true
+        }
     }
+    return false
+}
 
-    return fmt.Sprintf("%s:%-3d", file, line)
+// goroutineTag is the short tag of the goroutine and labels of the entry,
+// like "g17 worker=3".
+func goroutineTag(entry FormatterInput) string {
+    var parts []string
+    if id := entry.GetGoroutine(); id != 0 {
+        parts = append(parts, fmt.Sprintf("g%d", id))
+    }
+    labels := entry.GetLabels()
+    keys := make([]string, 0, len(labels))
+    for k := range labels {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    for _, k := range keys {
+        parts = append(parts, k+"="+labels[k])
+    }
+    return strings.Join(parts, " ")
 }
 
-func (f *TextFormatter) printColored(b *bytes.Buffer, entry FormatterInput, keys []string, timestampFormat string, callDepth int) {
-    var levelColor int
-    switch entry.GetLevel() {
-    case DebugLevel:
-        levelColor = gray
-    case WarnLevel:
-        levelColor = yellow
-    case ErrorLevel, FatalLevel, PanicLevel:
-        levelColor = red
-    default:
-        levelColor = blue
+var goroutineColors = []Color{red, green, yellow, blue, magenta, cyan}
+
+// goroutineColor keeps the color of a goroutine from one line to the next.
+func goroutineColor(id int64) Color {
+    if id == 0 {
+        return gray
     }
+    return goroutineColors[id%int64(len(goroutineColors))]
+}
 
-    codeSrc := entry.GetData()[moduleKey]
-    if callDepth > 0 {
-        codeSrc = formatShortFile(callDepth)
+// moduleColumn returns the module, or the caller, shown in the header, cut to
+// ModuleWidth, and the spaces padding it to ModuleWidth.
+func (f *TextFormatter) moduleColumn(entry FormatterInput, caller *runtime.Frame) (string, string) {
+    text := fmt.Sprint(entry.GetData()[moduleKey])
+    if caller != nil {
+        text = formatShortFile(caller)
+    }
+    if f.ModuleWidth <= 0 {
+        return text, ""
+    }
+    if displayWidth(text) > f.ModuleWidth {
+        if f.ModuleWidth <= 3 {
+            text = tailWidth(text, f.ModuleWidth)
+        } else {
+            text = "..." + tailWidth(text, f.ModuleWidth-3)
+        }
     }
-    levelText := strings.ToUpper(entry.GetLevel().String())
+    return text, strings.Repeat(" ", f.ModuleWidth-displayWidth(text))
+}
 
-    if !f.FullTimestamp {
-        fmt.Fprintf(b, "\x1b[%dm %s%-44s  (%s)[%04d]\x1b[0m", levelColor, levelText, entry.GetMessage(), codeSrc, miniTS())
-    } else {
-        fmt.Fprintf(b, "\x1b[%dm %s %-44s  (%s)[%s]\x1b[0m", levelColor, levelText, entry.GetMessage(), codeSrc, entry.GetTime().Format(timestampFormat))
+func (f *TextFormatter) printColored(b *bytes.Buffer, entry FormatterInput, keys []string, timestampFormat string, caller *runtime.Frame, width int) {
+    levelColor := f.Theme.levelColor(entry.GetLevel())
+
+    codeSrc, padding := f.moduleColumn(entry, caller)
+    if caller != nil && f.HyperlinkTemplate != "" {
+        codeSrc = f.hyperlink(caller.File, caller.Line, codeSrc)
+    }
+    module := fmt.Sprint(entry.GetData()[moduleKey])
+    if color := f.Theme.moduleColor(module, f.ColorModules); color != nocolor {
+        codeSrc = fmt.Sprintf("\x1b[%sm%s\x1b[0m\x1b[%sm", color, codeSrc, levelColor)
+    }
+    codeSrc += padding
+    levelText := f.Theme.label(entry.GetLevel())
+
+    left := fmt.Sprintf("\x1b[%sm %s", levelColor, levelText)
+    right := fmt.Sprintf("  (%s)[%04d]\x1b[0m", codeSrc, miniTS())
+    if f.FullTimestamp {
+        left += " "
+        right = fmt.Sprintf("  (%s)[%s]\x1b[0m", codeSrc, entry.GetTime().Format(timestampFormat))
     }
+    if tag := goroutineTag(entry); tag != "" {
+        right += fmt.Sprintf(" \x1b[%sm[%s]\x1b[0m", goroutineColor(entry.GetGoroutine()), tag)
+    }
+    b.WriteString(layoutHeader(left, entry.GetMessage(), right, width, levelColor))
     for _, k := range keys {
         if k == moduleKey {
             continue
         }
-        value := fmt.Sprintf("%+v", entry.GetData()[k])
-        fmt.Fprintf(b, "\n      \x1b[%dm- %-8s = %+v \x1b[0m", gray, k, tripHeadAndTail(value, 128))
+        f.renderField(b, k, entry.GetData()[k], width, true)
     }
 
     jsonRaw := entry.GetJsonRaw()
     if jsonRaw != nil {
-        fmt.Fprintf(b, "\x1b[%dm \n%s \x1b[0m", gray, prettyJSON(jsonRaw))
+        fmt.Fprintf(b, "\x1b[%sm \n%s \x1b[0m", f.Theme.fieldValue(), prettyJSON(jsonRaw))
+    }
+}
+
+// renderField prints a field on its own lines, the stack traces, diffs,
+// tables and binary data below their key, the other values cut to the width.
+func (f *TextFormatter) renderField(b *bytes.Buffer, key string, value interface{}, width int, colored bool) {
+    valueColor := nocolor
+    if colored {
+        valueColor = f.Theme.fieldValue()
+    }
+    writeKey := func(rest string) {
+        if colored {
+            f.writeColoredField(b, key, rest)
+        } else {
+            fmt.Fprintf(b, "\n     - %s =%s", padRight(key, fieldKeyWidth), rest)
+        }
+    }
+
+    switch v := value.(type) {
+    case StackTrace:
+        writeKey("")
+        writeStackTrace(b, v, valueColor)
+    case Diff:
+        writeKey("")
+        writeDiff(b, v, colored)
+    case Table:
+        writeKey("")
+        writeTable(b, v, "         ", f.TableCellWidth, f.TableMaxRows, valueColor)
+    case BinaryData:
+        writeKey(fmt.Sprintf(" (%d bytes)", len(v)))
+        writeHexDump(b, v, "         ", f.BinaryMaxBytes, valueColor)
+    default:
+        text := " " + tripHeadAndTail(fmt.Sprintf("%+v", value), valueWidth(width))
+        if colored {
+            text += " "
+        }
+        writeKey(text)
+    }
+}
+
+// writeColoredField prints the key of a field, followed by the rest of its
+// line, like " value".
+func (f *TextFormatter) writeColoredField(b *bytes.Buffer, key, rest string) {
+    keyColor, valueColor := f.Theme.fieldKey(), f.Theme.fieldValue()
+    if rest == "" || keyColor == valueColor {
+        fmt.Fprintf(b, "\n      \x1b[%sm- %s =%s\x1b[0m", keyColor, padRight(key, fieldKeyWidth), rest)
+        return
+    }
+    fmt.Fprintf(b, "\n      \x1b[%sm- %s =\x1b[0m\x1b[%sm%s\x1b[0m", keyColor, padRight(key, fieldKeyWidth), valueColor, rest)
+}
+
+// layoutWidth is the number of columns entries are laid out in, or 0 for the
+// fixed layout.
+func (f *TextFormatter) layoutWidth(term *outputTerminal) int {
+    if f.TerminalWidth != 0 {
+        if f.TerminalWidth < 0 {
+            return 0
+        }
+        return f.TerminalWidth
+    }
+    return term.width()
+}
+
+// layoutHeader lays out the first line of an entry: left, the message, then
+// right aligned to the edge of width columns. Messages too long for that
+// line wrap under their start, the continuation lines are printed in color.
+// Without a width the message is padded to 44 columns.
+func layoutHeader(left, message, right string, width int, color Color) string {
+    indent := displayWidth(left)
+    room := width - indent - displayWidth(right)
+    if width <= 0 || room < minMessageWidth {
+        return left + padRight(message, defaultMessageWidth) + right
+    }
+
+    lines := wrapWidth(message, room, width-indent)
+    header := left + padRight(lines[0], room) + right
+    for _, line := range lines[1:] {
+        line = strings.Repeat(" ", indent) + line
+        if color != nocolor {
+            line = fmt.Sprintf("\x1b[%sm%s\x1b[0m", color, line)
+        }
+        header += "\n" + line
+    }
+    return header
+}
+
+// valueWidth is the number of columns field values are cut at.
+func valueWidth(width int) int {
+    if width <= 0 {
+        return defaultValueWidth
+    }
+    // Room left after the key, the "..." of tripHeadAndTail, which rounds
+    // odd counts up, and the space closing colored values.
+    if width -= fieldPrefixWidth + len("...") + 1 + 1; width < minValueWidth {
+        return minValueWidth
     }
+    return width
 }
 
+// tripHeadAndTail keeps about count columns of src, half from its head and
+// half from its tail, without cutting through a character.
 func tripHeadAndTail(src string, count int) string {
-    length := len(src)
-    if length <= count {
+    if displayWidth(src) <= count {
         return src
     }
 
     if count%2 != 0 {
         count ++
     }
-    return src[:count/2] + "..." + src[length-count/2:length-1]
+    return headWidth(src, count/2) + "..." + tailWidth(src, count/2)
 }
 
 func needsQuoting(text string) bool {
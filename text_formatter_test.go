@@ -3,17 +3,22 @@ package zlog
 import (
 	"bytes"
 	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestQuoting(t *testing.T) {
 	tf := &TextFormatter{DisableColors: true}
 
 	checkQuoting := func(q bool, value interface{}) {
-		b, _ := tf.Format(WithField("test", value),0)
-		idx := bytes.Index(b, ([]byte)("test="))
-		cont := bytes.Contains(b[idx+5:], []byte{'"'})
+		var b bytes.Buffer
+		tf.appendKeyValue(&b, "test", value)
+		cont := bytes.Contains(b.Bytes(), []byte{'"'})
 		if cont != q {
 			if q {
 				t.Errorf("quoting expected for: %#v", value)
@@ -36,15 +41,12 @@ func TestQuoting(t *testing.T) {
 func TestTimestampFormat(t *testing.T) {
 	checkTimeStr := func(format string) {
 		customFormatter := &TextFormatter{DisableColors: true, TimestampFormat: format}
-		customStr, _ := customFormatter.Format(WithField("test", "test"),0)
-		timeStart := bytes.Index(customStr, ([]byte)("time="))
-		timeEnd := bytes.Index(customStr, ([]byte)("level="))
-		timeStr := customStr[timeStart+5 : timeEnd-1]
-		if timeStr[0] == '"' && timeStr[len(timeStr)-1] == '"' {
-			timeStr = timeStr[1 : len(timeStr)-1]
-		}
+		customStr, _ := customFormatter.Format(WithField("test", "test"))
+		timeStart := bytes.Index(customStr, ([]byte)(")["))
+		timeEnd := bytes.IndexByte(customStr[timeStart:], ']') + timeStart
+		timeStr := customStr[timeStart+2 : timeEnd]
 		if format == "" {
-			format = time.RFC3339
+			format = DefaultTimestampFormat
 		}
 		_, e := time.Parse(format, (string)(timeStr))
 		if e != nil {
@@ -59,3 +61,94 @@ func TestTimestampFormat(t *testing.T) {
 
 // TODO add tests for sorting etc., this requires a parser for the text
 // formatter output.
+
+func TestTerminalLayout(t *testing.T) {
+	entry := &Entry{Logger: New("module"), Data: Fields{"key": strings.Repeat("v", 200)}, Time: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Level: InfoLevel}
+	entry.Data[moduleKey] = "module"
+
+	entry.Message = "short message"
+	b, _ := (&TextFormatter{DisableColors: true, TerminalWidth: 80}).Format(entry)
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, 80, displayWidth(lines[0]))
+	assert.True(t, strings.HasSuffix(lines[0], "  (module)[2020-01-02 03:04:05]"))
+	assert.Equal(t, "     - key      = "+strings.Repeat("v", 28)+"..."+strings.Repeat("v", 28), lines[1])
+
+	entry.Message = "a message long enough to wrap below the first line of the entry"
+	b, _ = (&TextFormatter{DisableColors: true, TerminalWidth: 80}).Format(entry)
+	lines = strings.Split(string(b), "\n")
+	level := InfoLevel.String()
+	assert.Equal(t, level+"a message long enough to wrap below the       (module)[2020-01-02 03:04:05]", lines[0])
+	assert.Equal(t, strings.Repeat(" ", displayWidth(level))+"first line of the entry", lines[1])
+
+	b, _ = (&TextFormatter{DisableColors: true, TerminalWidth: -1}).Format(entry)
+	assert.Contains(t, string(b), level+entry.Message+"  (module)")
+	assert.Contains(t, string(b), strings.Repeat("v", 64)+"..."+strings.Repeat("v", 64))
+}
+
+func TestColorsFollowOutput(t *testing.T) {
+	for _, name := range []string{"NO_COLOR", "FORCE_COLOR", "TERM"} {
+		defer os.Setenv(name, os.Getenv(name))
+	}
+	os.Unsetenv("NO_COLOR")
+	os.Unsetenv("FORCE_COLOR")
+	os.Setenv("TERM", "xterm")
+
+	logger := New("module")
+	var b bytes.Buffer
+	logger.Out = &b
+	term := logger.outputTerminal()
+	assert.False(t, term.isTerminal)
+	assert.False(t, term.colors)
+	assert.True(t, term == logger.outputTerminal())
+
+	file, err := ioutil.TempFile("", "zlog")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	defer file.Close()
+	logger.Out = file
+	assert.True(t, term != logger.outputTerminal())
+	assert.False(t, logger.outputTerminal().isTerminal)
+
+	os.Setenv("FORCE_COLOR", "1")
+	assert.True(t, colorsFromEnv(false))
+	os.Setenv("NO_COLOR", "1")
+	assert.False(t, colorsFromEnv(true))
+	os.Unsetenv("NO_COLOR")
+	os.Unsetenv("FORCE_COLOR")
+	os.Setenv("TERM", "dumb")
+	assert.False(t, colorsFromEnv(true))
+	os.Setenv("TERM", "xterm")
+	assert.True(t, colorsFromEnv(true))
+}
+
+// renderPlainField returns how TextFormatter prints the field key without
+// colors, to test the rendering of the field types.
+func renderPlainField(key string, value interface{}) string {
+	var b bytes.Buffer
+	(&TextFormatter{}).renderField(&b, key, value, 0, false)
+	return b.String()
+}
+
+func TestRenderField(t *testing.T) {
+	assert.Equal(t, "\n     - count    = 3", renderPlainField("count", 3))
+	assert.Equal(t, "\n     - trace    =\n         main.main\n             main.go:3",
+		renderPlainField("trace", StackTrace{{Function: "main.main", File: "main.go", Line: 3}}))
+
+	var b bytes.Buffer
+	tf := &TextFormatter{Theme: Theme{FieldKey: "36", FieldValue: "33"}}
+	tf.renderField(&b, "count", 3, 0, true)
+	assert.Equal(t, "\n      \x1b[36m- count    =\x1b[0m\x1b[33m 3 \x1b[0m", b.String())
+}
+
+func TestFieldValuesFitTheWidth(t *testing.T) {
+	long := strings.Repeat("abcdefghij", 20)
+	for _, width := range []int{70, 71, 100} {
+		var b bytes.Buffer
+		(&TextFormatter{}).renderField(&b, "key", long, width, true)
+		// Odd counts are rounded up by tripHeadAndTail, even ones leave a
+		// column.
+		used := displayWidth(strings.TrimPrefix(b.String(), "\n"))
+		assert.True(t, used == width || used == width-1, "%d columns of %d", used, width)
+	}
+}
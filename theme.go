@@ -0,0 +1,199 @@
+package zlog
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// Color is the SGR parameters of a terminal color, like "31" for red or
+// "38;5;208" for the orange of the 256 colors palette.
+type Color string
+
+// ANSIColor is one of the 16 basic colors, 30 to 37 and their bright
+// variants 90 to 97.
+func ANSIColor(code int) Color {
+	return Color(fmt.Sprint(code))
+}
+
+// Color256 is one of the colors of the 256 colors palette.
+func Color256(n uint8) Color {
+	return Color(fmt.Sprintf("38;5;%d", n))
+}
+
+// TrueColor is a 24 bit color, for the terminals supporting them.
+func TrueColor(r, g, b uint8) Color {
+	return Color(fmt.Sprintf("38;2;%d;%d;%d", r, g, b))
+}
+
+// Bold returns c in bold.
+func (c Color) Bold() Color {
+	if c == nocolor {
+		return "1"
+	}
+	return "1;" + c
+}
+
+// Theme sets the colors and level labels of TextFormatter. Its zero value,
+// and any level or color left out, use the colors of defaultTheme.
+type Theme struct {
+	// Color of the header of the entries of each level.
+	Levels map[Level]Color
+	// Text printed before the message of each level, see ArrowLabels(),
+	// WordLabels() and EmojiLabels(). Labels are also used without colors.
+	Labels map[Level]string
+	// Colors of the keys and values of fields, values include the stack
+	// traces, tables and hex dumps, and the JSON raw.
+	FieldKey   Color
+	FieldValue Color
+	// Color of the module or caller column, by default the one of the level.
+	Module Color
+	// Colors of the column of given modules, by module name.
+	Modules map[string]Color
+	// Colors given to the other modules with TextFormatter.ColorModules,
+	// defaults to the basic colors other than the ones of warnings and
+	// errors, and their bright variants.
+	ModulePalette []Color
+}
+
+// ArrowLabels are the labels of Level.String(), used by default.
+func ArrowLabels() map[Level]string {
+	labels := make(map[Level]string, len(AllLevels))
+	for _, level := range AllLevels {
+		labels[level] = level.String()
+	}
+	return labels
+}
+
+// WordLabels name the levels, aligned on the longest.
+func WordLabels() map[Level]string {
+	return map[Level]string{
+		DebugLevel: "DEBUG ",
+		InfoLevel:  "INFO  ",
+		WarnLevel:  "WARN  ",
+		ErrorLevel: "ERROR ",
+		FatalLevel: "FATAL ",
+		PanicLevel: "PANIC ",
+	}
+}
+
+// EmojiLabels are wide characters, aligned by their display width.
+func EmojiLabels() map[Level]string {
+	return map[Level]string{
+		DebugLevel: "🐛 ",
+		InfoLevel:  "💬 ",
+		WarnLevel:  "🚧 ",
+		ErrorLevel: "❌ ",
+		FatalLevel: "💀 ",
+		PanicLevel: "🔥 ",
+	}
+}
+
+// DefaultTheme uses the 8 basic colors.
+func DefaultTheme() Theme {
+	return Theme{
+		Levels: map[Level]Color{
+			DebugLevel: gray,
+			InfoLevel:  blue,
+			WarnLevel:  yellow,
+			ErrorLevel: red,
+			FatalLevel: red,
+			PanicLevel: red,
+		},
+		Labels:     ArrowLabels(),
+		FieldKey:   gray,
+		FieldValue: gray,
+	}
+}
+
+// DarkTheme uses the 256 colors palette, with light colors readable on a
+// dark background.
+func DarkTheme() Theme {
+	return Theme{
+		Levels: map[Level]Color{
+			DebugLevel: Color256(245),
+			InfoLevel:  Color256(75),
+			WarnLevel:  Color256(214),
+			ErrorLevel: Color256(203),
+			FatalLevel: Color256(203).Bold(),
+			PanicLevel: Color256(201).Bold(),
+		},
+		Labels:     WordLabels(),
+		FieldKey:   Color256(109),
+		FieldValue: Color256(250),
+		Module:     Color256(141),
+	}
+}
+
+// LightTheme uses the 256 colors palette, with dark colors readable on a
+// light background.
+func LightTheme() Theme {
+	return Theme{
+		Levels: map[Level]Color{
+			DebugLevel: Color256(242),
+			InfoLevel:  Color256(25),
+			WarnLevel:  Color256(130),
+			ErrorLevel: Color256(160),
+			FatalLevel: Color256(160).Bold(),
+			PanicLevel: Color256(90).Bold(),
+		},
+		Labels:     WordLabels(),
+		FieldKey:   Color256(30),
+		FieldValue: Color256(238),
+		Module:     Color256(90),
+	}
+}
+
+// defaultTheme fills in what a Theme leaves out. It is never handed out, so
+// it cannot be changed from outside the package.
+var defaultTheme = DefaultTheme()
+
+func (t Theme) levelColor(level Level) Color {
+	if c, ok := t.Levels[level]; ok {
+		return c
+	}
+	if c, ok := defaultTheme.Levels[level]; ok {
+		return c
+	}
+	return blue
+}
+
+var defaultModulePalette = []Color{green, blue, magenta, cyan, "92", "94", "95", "96"}
+
+// moduleColor returns the color of the column of module, hashed into the
+// palette when hashed is set.
+func (t Theme) moduleColor(module string, hashed bool) Color {
+	if c, ok := t.Modules[module]; ok {
+		return c
+	}
+	if !hashed {
+		return t.Module
+	}
+	palette := t.ModulePalette
+	if len(palette) == 0 {
+		palette = defaultModulePalette
+	}
+	h := fnv.New32a()
+	h.Write([]byte(module))
+	return palette[h.Sum32()%uint32(len(palette))]
+}
+
+func (t Theme) label(level Level) string {
+	if label, ok := t.Labels[level]; ok {
+		return label
+	}
+	return level.String()
+}
+
+func (t Theme) fieldKey() Color {
+	if t.FieldKey == nocolor {
+		return defaultTheme.FieldKey
+	}
+	return t.FieldKey
+}
+
+func (t Theme) fieldValue() Color {
+	if t.FieldValue == nocolor {
+		return defaultTheme.FieldValue
+	}
+	return t.FieldValue
+}
@@ -0,0 +1,87 @@
+package zlog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColors(t *testing.T) {
+	assert.Equal(t, Color("91"), ANSIColor(91))
+	assert.Equal(t, Color("38;5;208"), Color256(208))
+	assert.Equal(t, Color("38;2;255;128;0"), TrueColor(255, 128, 0))
+	assert.Equal(t, Color("1;31"), red.Bold())
+}
+
+func TestTheme(t *testing.T) {
+	entry := &Entry{Logger: New("module"), Data: Fields{moduleKey: "module", "key": "value"}, Message: "hello", Level: WarnLevel}
+
+	theme := Theme{
+		Levels:     map[Level]Color{WarnLevel: TrueColor(255, 128, 0)},
+		Labels:     WordLabels(),
+		FieldKey:   Color256(109),
+		FieldValue: Color256(250),
+		Module:     Color256(141),
+	}
+	b, err := (&TextFormatter{ForceColors: true, TerminalWidth: -1, Theme: theme}).Format(entry)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "\x1b[38;2;255;128;0m WARN  hello")
+	assert.Contains(t, string(b), "(\x1b[38;5;141mmodule\x1b[0m\x1b[38;2;255;128;0m)")
+	assert.Contains(t, string(b), "\x1b[38;5;109m- key      =\x1b[0m\x1b[38;5;250m value \x1b[0m")
+
+	b, err = (&TextFormatter{ForceColors: true, TerminalWidth: -1}).Format(entry)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "\x1b[33m **** hello")
+	assert.Contains(t, string(b), "\x1b[37m- key      = value \x1b[0m")
+
+	b, err = (&TextFormatter{DisableColors: true, TerminalWidth: -1, Theme: Theme{Labels: EmojiLabels()}}).Format(entry)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "🚧 hello"+strings.Repeat(" ", 39)+"  (module)")
+}
+
+func TestModuleColors(t *testing.T) {
+	theme := Theme{Modules: map[string]Color{"db": red}}
+	assert.Equal(t, red, theme.moduleColor("db", false))
+	assert.Equal(t, nocolor, theme.moduleColor("api", false))
+	assert.Equal(t, theme.moduleColor("api", true), theme.moduleColor("api", true))
+	assert.Contains(t, defaultModulePalette, theme.moduleColor("api", true))
+
+	seen := make(map[Color]bool)
+	for _, module := range []string{"module1", "module2", "module3", "module4", "module5"} {
+		seen[theme.moduleColor(module, true)] = true
+	}
+	assert.True(t, len(seen) > 1)
+
+	theme.ModulePalette = []Color{Color256(208)}
+	assert.Equal(t, Color256(208), theme.moduleColor("api", true))
+
+	entry := &Entry{Logger: New("db"), Data: Fields{moduleKey: "db"}, Message: "hello", Level: InfoLevel}
+	b, err := (&TextFormatter{ForceColors: true, TerminalWidth: -1, Theme: theme, ColorModules: true, ModuleWidth: 6}).Format(entry)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "  (\x1b[31mdb\x1b[0m\x1b[34m    )[")
+}
+
+func TestModuleWidth(t *testing.T) {
+	format := func(module string, width int) string {
+		entry := &Entry{Logger: New(module), Data: Fields{moduleKey: module}, Message: "hello", Level: InfoLevel}
+		b, _ := (&TextFormatter{DisableColors: true, TerminalWidth: -1, ModuleWidth: width}).Format(entry)
+		return string(b)
+	}
+	assert.Contains(t, format("api", 8), "  (api     )[")
+	assert.Contains(t, format("storage/reader", 8), "  (...eader)[")
+	assert.Contains(t, format("storage", 2), "  (ge)[")
+	assert.Contains(t, format("storage", 0), "  (storage)[")
+}
+
+func TestThemesAreCopies(t *testing.T) {
+	theme := DarkTheme()
+	theme.Levels[WarnLevel] = red
+	theme.Labels[WarnLevel] = "W "
+	assert.Equal(t, Color256(214), DarkTheme().Levels[WarnLevel])
+	assert.Equal(t, "WARN  ", DarkTheme().Labels[WarnLevel])
+
+	for _, level := range AllLevels {
+		assert.Equal(t, level.String(), DefaultTheme().label(level))
+	}
+}
@@ -10,12 +10,12 @@ import (
 var (
 	chains map[int]*TraceChain
 
-	colors map[int]bool
+	colors map[Color]bool
 )
 
 func init() {
 	chains = make(map[int]*TraceChain)
-	colors = make(map[int]bool)
+	colors = make(map[Color]bool)
 
 	colors[red] = false
 	colors[green] = false
@@ -101,7 +101,7 @@ func TraceEnd(index int) {
 	}
 }
 
-func newTraceChain(color int) *TraceChain {
+func newTraceChain(color Color) *TraceChain {
 	return &TraceChain{
 		blocks: list.New(),
 		color:  color,
@@ -114,10 +114,10 @@ func (tc *TraceChain) addBlock(block *TraceBlock) {
 
 type TraceChain struct {
 	blocks *list.List
-	color  int
+	color  Color
 }
 
-func newTraceBlock(color int, args []interface{}, obj interface{}, fields Fields) *TraceBlock {
+func newTraceBlock(color Color, args []interface{}, obj interface{}, fields Fields) *TraceBlock {
 	tb := TraceBlock{
 		Fields: fields,
 		Obj:    obj,
@@ -131,20 +131,20 @@ type TraceBlock struct {
 	Fields Fields
 	Obj    interface{}
 	args   []interface{}
-	color  int
+	color  Color
 }
 
 func printBlock(block *TraceBlock) {
 	b := bytes.NewBuffer([]byte{})
 	message := fmt.Sprint(block.args...)
-	fmt.Fprintf(b, "\x1b[%dm msg: %-44s \x1b[0m", block.color, message)
+	fmt.Fprintf(b, "\x1b[%sm msg: %s \x1b[0m", block.color, padRight(message, 44))
 
 	for k, v := range block.Fields {
 		value := fmt.Sprintf("%+v", v)
-		if len(value) > 128 {
-			value = value[:128] + "..."
+		if displayWidth(value) > 128 {
+			value = headWidth(value, 128) + "..."
 		}
-		fmt.Fprintf(b, "\n     \x1b[%dm- %-8s = %+v \x1b[0m", block.color, k, value)
+		fmt.Fprintf(b, "\n     \x1b[%sm- %s = %+v \x1b[0m", block.color, padRight(k, 8), value)
 	}
 
 	jsonRaw, err := json.Marshal(block.Obj)
@@ -152,7 +152,7 @@ func printBlock(block *TraceBlock) {
 		panic(err)
 	}
 	if jsonRaw != nil {
-		fmt.Fprintf(b, "\x1b[%dm \n%s \x1b[0m", block.color, prettyJSON(jsonRaw))
+		fmt.Fprintf(b, "\x1b[%sm \n%s \x1b[0m", block.color, prettyJSON(jsonRaw))
 	}
 	fmt.Fprintf(b, "\n---------------------------------------------------\n")
 
@@ -0,0 +1,267 @@
+package zlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultMaxBodySize = 4096
+
+// maxTrackedRequests bounds the requests a Transport remembers to count
+// their attempts.
+const maxTrackedRequests = 64
+
+// Headers whose values are never logged.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// Transport is an http.RoundTripper logging each outbound request with its
+// response, which is the easiest way to see what a third party integration
+// really sends and receives:
+//
+//	client := &http.Client{Transport: zlog.NewTransport(logger, nil)}
+//
+// Create it with NewTransport, the levels have to be set.
+//
+// Retries are logged with the number of the attempt. Sending the same
+// *http.Request again counts as a retry, a client building a new request
+// for each attempt gives its number with ContextWithAttempt.
+type Transport struct {
+	// Does the actual requests, http.DefaultTransport when nil.
+	Base   http.RoundTripper
+	Logger *Logger
+
+	// Level of the successful exchanges.
+	Level Level
+	// Level of the exchanges failing with an error or a 4xx or 5xx status.
+	ErrorLevel Level
+
+	// Query parameters whose values are left out of the logged URLs, like
+	// API keys.
+	RedactParams []string
+
+	// Log the request and response headers, the ones carrying credentials
+	// are redacted.
+	DumpHeaders bool
+	// Log the request and response bodies up to MaxBodySize bytes, JSON
+	// bodies are pretty printed.
+	DumpBodies  bool
+	MaxBodySize int
+
+	mu       sync.Mutex
+	attempts map[*http.Request]int
+	requests []*http.Request
+}
+
+type attemptContextKey struct{}
+
+// ContextWithAttempt returns a context telling Transport that the request
+// it is given to is the attempt-th one, 1 being the first.
+func ContextWithAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptContextKey{}, attempt)
+}
+
+// NewTransport logs successful exchanges at Debug and failed ones at Warn.
+func NewTransport(logger *Logger, base http.RoundTripper) *Transport {
+	return &Transport{
+		Base:        base,
+		Logger:      logger,
+		Level:       DebugLevel,
+		ErrorLevel:  WarnLevel,
+		MaxBodySize: defaultMaxBodySize,
+	}
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *Transport) maxBodySize() int {
+	if t.MaxBodySize > 0 {
+		return t.MaxBodySize
+	}
+	return defaultMaxBodySize
+}
+
+// attempt returns the number of the attempt of req, from its context or by
+// counting the times it was seen, for the last maxTrackedRequests requests.
+func (t *Transport) attempt(req *http.Request) int {
+	if attempt, ok := req.Context().Value(attemptContextKey{}).(int); ok {
+		return attempt
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.attempts == nil {
+		t.attempts = make(map[*http.Request]int)
+	}
+	attempt := t.attempts[req] + 1
+	if attempt == 1 {
+		if len(t.requests) == maxTrackedRequests {
+			delete(t.attempts, t.requests[0])
+			t.requests = t.requests[1:]
+		}
+		t.requests = append(t.requests, req)
+	}
+	t.attempts[req] = attempt
+	return attempt
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	attempt := t.attempt(req)
+
+	var reqBody []byte
+	if t.DumpBodies && req.Body != nil && req.Body != http.NoBody {
+		// A RoundTripper must not modify the request it is given.
+		req = req.WithContext(req.Context())
+		reqBody, req.Body = peekBody(req.Body, t.maxBodySize())
+	}
+
+	resp, err := t.base().RoundTrip(req)
+
+	fields := Fields{
+		"method":   req.Method,
+		"url":      t.redactURL(req.URL),
+		"duration": time.Since(start).String(),
+	}
+	if attempt > 1 {
+		fields["attempt"] = attempt
+	}
+	level := t.Level
+	if err != nil {
+		fields[ErrorKey] = err
+		level = t.ErrorLevel
+	} else {
+		fields["status"] = resp.StatusCode
+		if resp.StatusCode >= 400 {
+			level = t.ErrorLevel
+		}
+	}
+
+	entry := t.Logger.WithFields(fields)
+	if t.DumpHeaders {
+		entry = entry.WithMultiLines("request header", formatHeader(req.Header))
+		if resp != nil {
+			entry = entry.WithMultiLines("response header", formatHeader(resp.Header))
+		}
+	}
+	if t.DumpBodies {
+		var respBody []byte
+		if resp != nil && resp.Body != nil {
+			respBody, resp.Body = peekBody(resp.Body, t.maxBodySize())
+		}
+		entry = t.withBodies(entry, req.Header, reqBody, respHeader(resp), respBody)
+	}
+	entry.logLevel(level, fmt.Sprintf("%s %s", req.Method, req.URL.Host))
+
+	return resp, err
+}
+
+// peekBody reads up to max+1 bytes of body, enough to tell it is longer than
+// max, and returns a body still holding all of them for the caller.
+func peekBody(body io.ReadCloser, max int) ([]byte, io.ReadCloser) {
+	peeked, _ := ioutil.ReadAll(io.LimitReader(body, int64(max)+1))
+	return peeked, struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peeked), body), body}
+}
+
+func respHeader(resp *http.Response) http.Header {
+	if resp == nil {
+		return nil
+	}
+	return resp.Header
+}
+
+// The JSON raw of an entry holds one document, a JSON response is preferred
+// to a JSON request. Other bodies are logged as lines.
+func (t *Transport) withBodies(entry *Entry, reqHeader http.Header, reqBody []byte, respHeader http.Header, respBody []byte) *Entry {
+	max := t.maxBodySize()
+	var jsonRaw []byte
+	for _, body := range []struct {
+		key    string
+		header http.Header
+		data   []byte
+	}{
+		{"response body", respHeader, respBody},
+		{"request body", reqHeader, reqBody},
+	} {
+		if len(body.data) == 0 {
+			continue
+		}
+		if jsonRaw == nil && len(body.data) <= max && isJSON(body.header, body.data) {
+			jsonRaw = body.data
+			continue
+		}
+		text := string(body.data)
+		if len(body.data) > max {
+			text = fmt.Sprintf("%s\n... truncated at %d bytes", body.data[:max], max)
+		}
+		entry = entry.WithMultiLines(body.key, text)
+	}
+	// Adding fields drops the JSON raw, it comes last.
+	if jsonRaw != nil {
+		entry = entry.WithJsonRaw(jsonRaw)
+	}
+	return entry
+}
+
+func isJSON(header http.Header, data []byte) bool {
+	return strings.Contains(header.Get("Content-Type"), "json") && json.Valid(data)
+}
+
+func (t *Transport) redactURL(u *url.URL) string {
+	redacted := *u
+	if redacted.User != nil {
+		if _, ok := redacted.User.Password(); ok {
+			redacted.User = url.UserPassword(redacted.User.Username(), "REDACTED")
+		}
+	}
+	if len(t.RedactParams) > 0 && redacted.RawQuery != "" {
+		query := redacted.Query()
+		for _, param := range t.RedactParams {
+			if _, ok := query[param]; ok {
+				query.Set(param, "REDACTED")
+			}
+		}
+		redacted.RawQuery = query.Encode()
+	}
+	return redacted.String()
+}
+
+func formatHeader(header http.Header) string {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		value := strings.Join(header[k], ", ")
+		if sensitiveHeaders[http.CanonicalHeaderKey(k)] {
+			value = "REDACTED"
+		}
+		fmt.Fprintf(&b, "%s: %s\n", k, value)
+	}
+	return b.String()
+}
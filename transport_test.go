@@ -0,0 +1,186 @@
+package zlog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransportLogsExchange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+
+	logger, entries := newEntryChannelLogger("transport", 1)
+	transport := NewTransport(logger, nil)
+	transport.RedactParams = []string{"key"}
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get(server.URL + "/tea?key=secret&kind=green")
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	entry := <-entries
+	assert.Equal(t, WarnLevel, entry.level)
+	assert.Equal(t, "GET "+strings.TrimPrefix(server.URL, "http://"), entry.msg)
+	assert.Equal(t, "GET", entry.data["method"])
+	assert.Equal(t, server.URL+"/tea?key=REDACTED&kind=green", entry.data["url"])
+	assert.Equal(t, http.StatusTeapot, entry.data["status"])
+	assert.Contains(t, entry.data, "duration")
+}
+
+func TestTransportDumpsHeadersAndBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"echo":"` + string(body) + `"}`))
+	}))
+	defer server.Close()
+
+	logger := New("transport")
+	var logged FormatterInput
+	logger.Formatter = formatterFunc(func(entry FormatterInput) { logged = entry })
+	transport := NewTransport(logger, nil)
+	transport.DumpHeaders = true
+	transport.DumpBodies = true
+	client := &http.Client{Transport: transport}
+
+	req, _ := http.NewRequest("POST", server.URL, strings.NewReader("hello"))
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	// The body read for the log is still there for the caller.
+	assert.Equal(t, `{"echo":"hello"}`, string(body))
+	assert.Equal(t, body, logged.GetJsonRaw())
+	data := logged.GetData()
+	assert.Equal(t, "hello", data["request body-0"])
+	assert.Contains(t, data, "response header-0")
+	var authorization interface{}
+	for k, v := range data {
+		if strings.HasPrefix(k, "request header-") && strings.HasPrefix(v.(string), "Authorization:") {
+			authorization = v
+		}
+	}
+	assert.Equal(t, "Authorization: REDACTED", authorization)
+}
+
+func TestTransportTruncatesBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 20)))
+	}))
+	defer server.Close()
+
+	logger, entries := newEntryChannelLogger("transport", 1)
+	transport := NewTransport(logger, nil)
+	transport.DumpBodies = true
+	transport.MaxBodySize = 8
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Len(t, body, 20)
+
+	entry := <-entries
+	assert.Equal(t, DebugLevel, entry.level)
+	assert.Equal(t, "xxxxxxxx", entry.data["response body-0"])
+	assert.Equal(t, "... truncated at 8 bytes", entry.data["response body-1"])
+}
+
+func TestTransportTruncatesRequestBodies(t *testing.T) {
+	body := &countingReader{Reader: strings.NewReader(strings.Repeat("y", 20))}
+	var readBeforeSend int
+	var sent []byte
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		readBeforeSend = body.read
+		sent, _ = ioutil.ReadAll(req.Body)
+		return &http.Response{StatusCode: 200, Body: http.NoBody, Request: req}, nil
+	})
+
+	logger, entries := newEntryChannelLogger("transport", 1)
+	transport := NewTransport(logger, base)
+	transport.DumpBodies = true
+	transport.MaxBodySize = 8
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Post("http://example.com/", "text/plain", body)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	// Only what is logged is read before the request is sent, the rest is
+	// streamed.
+	assert.Equal(t, strings.Repeat("y", 20), string(sent))
+	entry := <-entries
+	assert.Equal(t, "yyyyyyyy", entry.data["request body-0"])
+	assert.Equal(t, "... truncated at 8 bytes", entry.data["request body-1"])
+	assert.Equal(t, 9, readBeforeSend)
+}
+
+type countingReader struct {
+	io.Reader
+	read int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += n
+	return n, err
+}
+
+func TestTransportLogsErrors(t *testing.T) {
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection reset")
+	})
+
+	logger, entries := newEntryChannelLogger("transport", 1)
+	client := &http.Client{Transport: NewTransport(logger, base)}
+
+	_, err := client.Get("http://example.com/")
+	assert.Error(t, err)
+
+	entry := <-entries
+	assert.Equal(t, WarnLevel, entry.level)
+	assert.EqualError(t, entry.data[ErrorKey].(error), "connection reset")
+}
+
+func TestTransportLogsRetries(t *testing.T) {
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody, Request: req}, nil
+	})
+
+	logger, entries := newEntryChannelLogger("transport", 3)
+	transport := NewTransport(logger, base)
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	for i := 0; i < 2; i++ {
+		resp, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.NotContains(t, (<-entries).data, "attempt")
+	assert.Equal(t, 2, (<-entries).data["attempt"])
+
+	req, _ = http.NewRequestWithContext(ContextWithAttempt(context.Background(), 3), "GET", "http://example.com/", nil)
+	resp, err := transport.RoundTrip(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 3, (<-entries).data["attempt"])
+}
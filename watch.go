@@ -0,0 +1,238 @@
+package zlog
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Distinct places a Watcher remembers changes from, the others are counted
+// together.
+const maxWatchSites = 8
+
+// Watch points a logger keeps, the one observed least recently is ended to
+// make room for a new one.
+const maxWatches = 256
+
+// Watcher follows a value over time, see Watch.
+type Watcher struct {
+	name   string
+	logger *Logger
+
+	mu       sync.Mutex
+	observed bool
+	// Only the last value is kept, as compared by WithDiff, with its JSON
+	// and itself when it is a scalar, to find it unchanged without a diff.
+	last         interface{}
+	lastRaw      []byte
+	lastScalar   interface{}
+	lastUsed     time.Time
+	observations int
+	changes      int
+	start        time.Time
+	sites        map[string]int
+	otherSites   int
+}
+
+// Watch returns the watch point called name on the standard logger, the same
+// one wherever it is called from until WatchEnd. Feed it with Observe.
+func Watch(name string) *Watcher {
+	return StandardLogger().Watch(name)
+}
+
+// Watch returns the watch point called name of the logger. A logger keeps
+// at most maxWatches of them, beyond that the one observed least recently is
+// ended, and observing it no longer reaches Watch.
+func (logger *Logger) Watch(name string) *Watcher {
+	root := logger.root()
+	root.watchesMu.Lock()
+	w, ok := root.watches[name]
+	var evicted *Watcher
+	if !ok {
+		if root.watches == nil {
+			root.watches = make(map[string]*Watcher)
+		}
+		if len(root.watches) >= maxWatches {
+			evicted = root.leastRecentWatch()
+			delete(root.watches, evicted.name)
+		}
+		w = &Watcher{name: name, logger: logger, sites: make(map[string]int), lastUsed: time.Now()}
+		root.watches[name] = w
+	}
+	root.watchesMu.Unlock()
+
+	if evicted != nil {
+		evicted.End()
+	}
+	return w
+}
+
+func (logger *Logger) leastRecentWatch() *Watcher {
+	var oldest *Watcher
+	var oldestUsed time.Time
+	for _, w := range logger.watches {
+		w.mu.Lock()
+		used := w.lastUsed
+		w.mu.Unlock()
+		if oldest == nil || used.Before(oldestUsed) {
+			oldest, oldestUsed = w, used
+		}
+	}
+	return oldest
+}
+
+// WatchEnd logs the summary of the watch point called name of the standard
+// logger and forgets it.
+func WatchEnd(name string) {
+	StandardLogger().WatchEnd(name)
+}
+
+// WatchEnd logs the summary of the watch point called name and forgets it.
+func (logger *Logger) WatchEnd(name string) {
+	root := logger.root()
+	root.watchesMu.Lock()
+	w, ok := root.watches[name]
+	root.watchesMu.Unlock()
+	if ok {
+		w.End()
+	}
+}
+
+// Observe logs value when it differs from the value observed last, with the
+// diff, the caller and the goroutine. The first value is logged whole.
+//
+// Scalars are compared as they are. Other values can be changed in place
+// between two calls, they are encoded each time and compared by their JSON,
+// which is only decoded and compared further when its bytes changed.
+func (w *Watcher) Observe(value interface{}) {
+	w.mu.Lock()
+	w.observations++
+	w.lastUsed = time.Now()
+	unchanged := w.observed && isScalar(value) && value == w.lastScalar
+	w.mu.Unlock()
+	if unchanged {
+		return
+	}
+
+	raw := diffRaw(value, w.logger.structOptions())
+	caller := w.logger.getCaller()
+
+	w.mu.Lock()
+	if w.observed && bytes.Equal(raw, w.lastRaw) {
+		w.mu.Unlock()
+		return
+	}
+	tree := decodeDiffTree(raw)
+	if w.observed && reflect.DeepEqual(tree, w.last) {
+		w.lastRaw = append([]byte(nil), raw...)
+		w.mu.Unlock()
+		return
+	}
+	first := !w.observed
+	var d Diff
+	if first {
+		w.start = time.Now()
+	} else {
+		d = Diff{}
+		diffValues(&d, "", w.last, tree)
+	}
+	w.observed = true
+	w.last = tree
+	// JSON given as bytes can be changed in place too.
+	w.lastRaw = append([]byte(nil), raw...)
+	w.lastScalar = nil
+	if isScalar(value) {
+		w.lastScalar = value
+	}
+	w.changes++
+	if caller != nil {
+		w.addSite(fmt.Sprintf("%s:%d", filepath.Base(caller.File), caller.Line))
+	}
+	w.mu.Unlock()
+
+	entry := w.logger.WithFields(Fields{"watch": w.name, "goroutine": currentGoroutineID()})
+	msg := fmt.Sprintf("watch %s: changed", w.name)
+	if first {
+		entry = entry.WithJsonRaw(w.lastRaw)
+		msg = fmt.Sprintf("watch %s: first value", w.name)
+	} else {
+		entry = entry.WithField("diff", d)
+	}
+	entry.Caller = caller
+	entry.logLevel(DebugLevel, msg)
+}
+
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case bool, string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr, float32, float64:
+		return true
+	}
+	return false
+}
+
+func (w *Watcher) addSite(site string) {
+	if _, ok := w.sites[site]; ok || len(w.sites) < maxWatchSites {
+		w.sites[site]++
+	} else {
+		w.otherSites++
+	}
+}
+
+// End logs how many values were observed and changed, and where from. The
+// watch point starts over when observed again.
+func (w *Watcher) End() {
+	root := w.logger.root()
+	root.watchesMu.Lock()
+	if root.watches[w.name] == w {
+		delete(root.watches, w.name)
+	}
+	root.watchesMu.Unlock()
+
+	w.mu.Lock()
+	fields := Fields{
+		"watch":        w.name,
+		"observations": w.observations,
+		"changes":      w.changes,
+	}
+	if w.observed {
+		fields["duration"] = time.Since(w.start).String()
+	}
+	if sites := w.formatSites(); sites != "" {
+		fields["sites"] = sites
+	}
+	w.observed = false
+	w.last, w.lastRaw, w.lastScalar = nil, nil, nil
+	w.observations, w.changes, w.otherSites = 0, 0, 0
+	w.sites = make(map[string]int)
+	w.mu.Unlock()
+
+	w.logger.WithFields(fields).logLevel(DebugLevel, fmt.Sprintf("watch %s: end", w.name))
+}
+
+// formatSites lists where the changes came from, most frequent first.
+func (w *Watcher) formatSites() string {
+	sites := make([]string, 0, len(w.sites))
+	for site := range w.sites {
+		sites = append(sites, site)
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		if w.sites[sites[i]] != w.sites[sites[j]] {
+			return w.sites[sites[i]] > w.sites[sites[j]]
+		}
+		return sites[i] < sites[j]
+	})
+
+	parts := make([]string, 0, len(sites)+1)
+	for _, site := range sites {
+		parts = append(parts, fmt.Sprintf("%s x%d", site, w.sites[site]))
+	}
+	if w.otherSites > 0 {
+		parts = append(parts, fmt.Sprintf("others x%d", w.otherSites))
+	}
+	return strings.Join(parts, ", ")
+}
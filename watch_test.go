@@ -0,0 +1,112 @@
+package zlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchLogsChanges(t *testing.T) {
+	logger, entries := newEntryChannelLogger("watch", 8)
+	w := logger.Watch("counter")
+	assert.True(t, w == logger.Watch("counter"))
+
+	state := map[string]int{"n": 0}
+	w.Observe(state)
+	w.Observe(state)
+	state["n"] = 1
+	w.Observe(state)
+	logger.WatchEnd("counter")
+	close(entries)
+
+	first := <-entries
+	assert.Equal(t, DebugLevel, first.level)
+	assert.Equal(t, "watch counter: first value", first.msg)
+	assert.Equal(t, "counter", first.data["watch"])
+	assert.Equal(t, currentGoroutineID(), first.data["goroutine"])
+
+	changed := <-entries
+	assert.Equal(t, "watch counter: changed", changed.msg)
+	assert.Equal(t, Diff{{Op: "replace", Path: "/n", Value: json1, Old: json0}}, changed.data["diff"])
+
+	end := <-entries
+	assert.Equal(t, "watch counter: end", end.msg)
+	assert.Equal(t, 3, end.data["observations"])
+	assert.Equal(t, 2, end.data["changes"])
+	assert.Regexp(t, `^watch_test.go:\d+ x1, watch_test.go:\d+ x1$`, end.data["sites"])
+
+	// The watch point starts over.
+	assert.True(t, w != logger.Watch("counter"))
+}
+
+func TestWatchesAreKeptByLogger(t *testing.T) {
+	a, _ := newEntryChannelLogger("watch", 0)
+	b, _ := newEntryChannelLogger("watch", 0)
+	assert.True(t, a.Watch("shared") != b.Watch("shared"))
+	assert.True(t, a.Watch("shared").logger == a)
+}
+
+func TestWatchesAreBounded(t *testing.T) {
+	logger, entries := newEntryChannelLogger("watch", maxWatches+1)
+	first := logger.Watch("w0")
+	for i := 1; i < maxWatches; i++ {
+		logger.Watch(fmt.Sprintf("w%d", i)).Observe(i)
+	}
+	// Asked for again, but observed less recently than the others.
+	assert.True(t, first == logger.Watch("w0"))
+
+	logger.Watch("one more")
+	assert.Len(t, logger.watches, maxWatches)
+	assert.NotContains(t, logger.watches, "w0")
+	assert.Contains(t, logger.watches, "w1")
+	close(entries)
+
+	var last loggedEntry
+	for entry := range entries {
+		last = entry
+	}
+	assert.Equal(t, "watch w0: end", last.msg)
+}
+
+func TestWatchUnchangedScalars(t *testing.T) {
+	logger, entries := newEntryChannelLogger("watch", 4)
+	w := logger.Watch("scalar")
+	w.Observe(1)
+	w.Observe(1)
+	w.Observe("1")
+	w.Observe([]byte(`{"a":1}`))
+	w.Observe(json.RawMessage(`{"a": 1}`))
+	close(entries)
+
+	var msgs []string
+	for entry := range entries {
+		msgs = append(msgs, entry.msg)
+	}
+	assert.Equal(t, []string{"watch scalar: first value", "watch scalar: changed", "watch scalar: changed"}, msgs)
+	assert.Equal(t, 5, w.observations)
+}
+
+var json0, json1 = diffTree(0, structOptions{}), diffTree(1, structOptions{})
+
+func TestWatchSitesAreBounded(t *testing.T) {
+	w := &Watcher{sites: make(map[string]int)}
+	for i := 0; i < maxWatchSites+3; i++ {
+		w.addSite(fmt.Sprintf("file.go:%d", i))
+	}
+	w.addSite("file.go:0")
+	w.addSite("file.go:0")
+
+	assert.Len(t, w.sites, maxWatchSites)
+	assert.Equal(t, 3, w.sites["file.go:0"])
+	assert.Equal(t, "file.go:0 x3, file.go:1 x1, file.go:2 x1, file.go:3 x1, file.go:4 x1, file.go:5 x1, file.go:6 x1, file.go:7 x1, others x3", w.formatSites())
+}
+
+func TestCurrentGoroutineID(t *testing.T) {
+	id := currentGoroutineID()
+	assert.NotZero(t, id)
+	other := make(chan int64)
+	go func() { other <- currentGoroutineID() }()
+	assert.NotEqual(t, id, <-other)
+}
@@ -0,0 +1,212 @@
+package zlog
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Characters taking two columns in a terminal: East Asian wide and fullwidth
+// characters, and emoji.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},
+	{0x231A, 0x231B},
+	{0x23E9, 0x23EC},
+	{0x2614, 0x2615},
+	{0x2648, 0x2653},
+	{0x26AA, 0x26AB},
+	{0x26BD, 0x26BE},
+	{0x26C4, 0x26C5},
+	{0x26F2, 0x26F5},
+	{0x2705, 0x2705},
+	{0x270A, 0x270B},
+	{0x274C, 0x274C},
+	{0x2753, 0x2757},
+	{0x2795, 0x2797},
+	{0x2B1B, 0x2B1C},
+	{0x2E80, 0x303E},
+	{0x3041, 0x33FF},
+	{0x3400, 0x4DBF},
+	{0x4E00, 0x9FFF},
+	{0xA000, 0xA4CF},
+	{0xA960, 0xA97F},
+	{0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF},
+	{0xFE10, 0xFE19},
+	{0xFE30, 0xFE6F},
+	{0xFF00, 0xFF60},
+	{0xFFE0, 0xFFE6},
+	{0x1F004, 0x1F004},
+	{0x1F0CF, 0x1F0CF},
+	{0x1F18E, 0x1F18E},
+	{0x1F191, 0x1F19A},
+	{0x1F200, 0x1F2FF},
+	{0x1F300, 0x1F64F},
+	{0x1F680, 0x1F6FF},
+	{0x1F7E0, 0x1F7EB},
+	{0x1F900, 0x1F9FF},
+	{0x1FA70, 0x1FAFF},
+	{0x20000, 0x2FFFD},
+	{0x30000, 0x3FFFD},
+}
+
+// Characters taking no column.
+var zeroWidthRanges = [][2]rune{
+	{0x200B, 0x200F},
+	{0x2028, 0x202E},
+	{0x2060, 0x2064},
+	{0xFE00, 0xFE0F},
+	{0xFEFF, 0xFEFF},
+	{0xE0100, 0xE01EF},
+}
+
+func inRanges(r rune, ranges [][2]rune) bool {
+	for _, rg := range ranges {
+		if r < rg[0] {
+			return false
+		}
+		if r <= rg[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// runeWidth is the number of columns r takes in a terminal.
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x20 || (r >= 0x7F && r < 0xA0):
+		return 0
+	case r < 0x1100:
+		if unicode.In(r, unicode.Mn, unicode.Me) {
+			return 0
+		}
+		return 1
+	case inRanges(r, zeroWidthRanges) || unicode.In(r, unicode.Mn, unicode.Me):
+		return 0
+	case inRanges(r, wideRanges):
+		return 2
+	}
+	return 1
+}
+
+// escapeLength is the length of the ANSI escape sequence at the start of s,
+// CSI sequences like colors and OSC sequences like hyperlinks, or 0.
+func escapeLength(s string) int {
+	if len(s) < 2 || s[0] != '\x1b' {
+		return 0
+	}
+	switch s[1] {
+	case '[':
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7E {
+				return i + 1
+			}
+		}
+		return len(s)
+	case ']':
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\a' {
+				return i + 1
+			}
+			if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return len(s)
+	}
+	return 0
+}
+
+// displayWidth is the number of columns s takes in a terminal, escape
+// sequences take none.
+func displayWidth(s string) int {
+	width := 0
+	for i := 0; i < len(s); {
+		if n := escapeLength(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		width += runeWidth(r)
+		i += size
+	}
+	return width
+}
+
+// padRight pads s with spaces to width columns, like "%-*s" does for ASCII.
+func padRight(s string, width int) string {
+	if n := width - displayWidth(s); n > 0 {
+		return s + strings.Repeat(" ", n)
+	}
+	return s
+}
+
+// headWidth returns the longest prefix of s taking at most width columns.
+func headWidth(s string, width int) string {
+	used := 0
+	for i := 0; i < len(s); {
+		if n := escapeLength(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if used+runeWidth(r) > width {
+			return s[:i]
+		}
+		used += runeWidth(r)
+		i += size
+	}
+	return s
+}
+
+// tailWidth returns the longest suffix of s taking at most width columns.
+func tailWidth(s string, width int) string {
+	used := 0
+	for i := len(s); i > 0; {
+		r, size := utf8.DecodeLastRuneInString(s[:i])
+		if used+runeWidth(r) > width {
+			return s[i:]
+		}
+		used += runeWidth(r)
+		i -= size
+	}
+	return s
+}
+
+// wrapWidth breaks s into lines at spaces and newlines, the first line taking
+// at most first columns and the next ones rest. Words longer than a line are
+// broken where they reach its end.
+func wrapWidth(s string, first, rest int) []string {
+	var lines []string
+	limit := func() int {
+		if len(lines) == 0 {
+			return first
+		}
+		return rest
+	}
+	for _, paragraph := range strings.Split(s, "\n") {
+		line := ""
+		for i, word := range strings.Split(paragraph, " ") {
+			if i > 0 && displayWidth(line+" "+word) <= limit() {
+				line += " " + word
+				continue
+			}
+			if i > 0 {
+				lines = append(lines, line)
+			}
+			line = word
+			for displayWidth(line) > limit() {
+				head := headWidth(line, limit())
+				if head == "" {
+					_, size := utf8.DecodeRuneInString(line)
+					head = line[:size]
+				}
+				lines = append(lines, head)
+				line = line[len(head):]
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
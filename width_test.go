@@ -0,0 +1,44 @@
+package zlog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisplayWidth(t *testing.T) {
+	assert.Equal(t, 5, displayWidth("hello"))
+	assert.Equal(t, 4, displayWidth("日本"))
+	assert.Equal(t, 6, displayWidth("ok 🚀!"))
+	assert.Equal(t, 4, displayWidth("café"))
+	assert.Equal(t, 3, displayWidth("\x1b[31mred\x1b[0m"))
+	assert.Equal(t, 4, displayWidth("\x1b]8;;http://x\x1b\\link\x1b]8;;\x1b\\"))
+}
+
+func TestPadRight(t *testing.T) {
+	assert.Equal(t, "日本  |", padRight("日本", 6)+"|")
+	assert.Equal(t, "\x1b[31mab\x1b[0m  |", padRight("\x1b[31mab\x1b[0m", 4)+"|")
+	assert.Equal(t, "toolong", padRight("toolong", 3))
+}
+
+func TestTripHeadAndTail(t *testing.T) {
+	assert.Equal(t, "short", tripHeadAndTail("short", 8))
+	assert.Equal(t, "abc...xyz", tripHeadAndTail("abcdefghijklmnopqrstuvwxyz", 6))
+	assert.Equal(t, "日...語", tripHeadAndTail("日本語の日本語", 5))
+
+	trimmed := tripHeadAndTail(strings.Repeat("é", 100), 9)
+	assert.Equal(t, strings.Repeat("é", 5)+"..."+strings.Repeat("é", 5), trimmed)
+}
+
+func TestCutCellWidth(t *testing.T) {
+	assert.Equal(t, "日本...", cutCell("日本語テキスト", 8))
+	assert.Equal(t, "日", cutCell("日本", 3))
+}
+
+func TestWrapWidth(t *testing.T) {
+	assert.Equal(t, []string{"the quick", "brown fox", "jumps"}, wrapWidth("the quick brown fox jumps", 10, 10))
+	assert.Equal(t, []string{"abc", "defghi", "jk"}, wrapWidth("abcdefghijk", 3, 6))
+	assert.Equal(t, []string{"one", "", "two"}, wrapWidth("one\n\ntwo", 10, 10))
+	assert.Equal(t, []string{"日本", "語"}, wrapWidth("日本語", 5, 5))
+}
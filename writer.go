@@ -6,6 +6,10 @@ import (
 	"runtime"
 )
 
+// Lines longer than this are logged in pieces, so that a program printing a
+// huge line does not make the writer fail as bufio.Scanner does past 64 KB.
+const maxWriterLineSize = 1024 * 1024
+
 func (logger *Logger) Writer() *io.PipeWriter {
 	return logger.WriterLevel(InfoLevel)
 }
@@ -31,19 +35,88 @@ func (logger *Logger) WriterLevel(level Level) *io.PipeWriter {
 		printFunc = logger.Print
 	}
 
-	go logger.writerScanner(reader, printFunc)
+	go logger.writerScanner(reader, func(line string) {
+		printFunc(line)
+	})
 	runtime.SetFinalizer(writer, writerFinalizer)
 
 	return writer
 }
 
-func (logger *Logger) writerScanner(reader *io.PipeReader, printFunc func(args ...interface{})) {
-	scanner := bufio.NewScanner(reader)
-	for scanner.Scan() {
-		printFunc(scanner.Text())
-	}
-	if err := scanner.Err(); err != nil {
-		logger.Errorf("Error while reading from Writer: %s", err)
+// WriterWithParser is WriterWithParserLevel falling back to InfoLevel.
+func (logger *Logger) WriterWithParser(parser LevelParser) *io.PipeWriter {
+	return logger.WriterWithParserLevel(parser, InfoLevel)
+}
+
+// WriterWithParserLevel returns a writer for the output of components which
+// prefix their lines with their level, like `[WARN] disk almost full`. Each
+// line is logged at the level parser finds, without the prefix, and at the
+// fallback level when it has none.
+func (logger *Logger) WriterWithParserLevel(parser LevelParser, fallback Level) *io.PipeWriter {
+	reader, writer := io.Pipe()
+
+	go logger.writerScanner(reader, func(line string) {
+		level, message, err := parseAndStrip(parser, line)
+		if err != nil {
+			logger.logLevel(fallback, line)
+			return
+		}
+		logger.logLevel(level, message)
+	})
+	runtime.SetFinalizer(writer, writerFinalizer)
+
+	return writer
+}
+
+// Writer returns a writer logging each line written to it with the fields of
+// the entry.
+func (entry *Entry) Writer() *io.PipeWriter {
+	return entry.WriterLevel(InfoLevel)
+}
+
+func (entry *Entry) WriterLevel(level Level) *io.PipeWriter {
+	writer, _ := entry.writerLevel(level)
+	return writer
+}
+
+// The returned channel is closed once the last line written has been logged.
+func (entry *Entry) writerLevel(level Level) (*io.PipeWriter, <-chan struct{}) {
+	reader, writer := io.Pipe()
+	done := entry.readerLevel(reader, level)
+	runtime.SetFinalizer(writer, writerFinalizer)
+
+	return writer, done
+}
+
+// readerLevel logs the lines read from reader until it is exhausted, then
+// closes the returned channel.
+func (entry *Entry) readerLevel(reader io.ReadCloser, level Level) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		entry.Logger.writerScanner(reader, func(line string) {
+			entry.logLevel(level, line)
+		})
+		close(done)
+	}()
+	return done
+}
+
+func (logger *Logger) writerScanner(reader io.ReadCloser, printFunc func(line string)) {
+	r := bufio.NewReader(reader)
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			if err != io.EOF {
+				logger.Errorf("Error while reading from Writer: %s", err)
+			}
+			break
+		}
+		line = append(line, chunk...)
+		if !isPrefix || len(line) >= maxWriterLineSize {
+			printFunc(string(line))
+			line = line[:0]
+		}
 	}
 	reader.Close()
 }
@@ -0,0 +1,97 @@
+package zlog
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type loggedLine struct {
+	level Level
+	msg   string
+}
+
+func receiveLines(t *testing.T, entries chan loggedEntry, count int) []loggedLine {
+	var received []loggedLine
+	for i := 0; i < count; i++ {
+		select {
+		case entry := <-entries:
+			received = append(received, loggedLine{entry.level, entry.msg})
+		case <-time.After(time.Second):
+			t.Fatalf("only %d of %d lines logged", i, count)
+		}
+	}
+	return received
+}
+
+func TestWriterWithParser(t *testing.T) {
+	for _, parser := range []LevelParser{NewRegexpParser(), &RegexpParser{}, &PrefixStrCmp{}} {
+		logger, lines := newEntryChannelLogger("writer", 4)
+		w := logger.WriterWithParserLevel(parser, WarnLevel)
+		fmt.Fprint(w, "[ERROR] disk full\n[INFO] retrying\nno prefix\n[DEBUG]\n")
+		w.Close()
+
+		assert.Equal(t, []loggedLine{
+			{ErrorLevel, "disk full"},
+			{InfoLevel, "retrying"},
+			{WarnLevel, "no prefix"},
+			{DebugLevel, ""},
+		}, receiveLines(t, lines, 4), "%T", parser)
+	}
+}
+
+func TestWriterWithParserRespectsLevel(t *testing.T) {
+	logger, lines := newEntryChannelLogger("writer", 2)
+	logger.SetLevel(WarnLevel)
+	w := logger.WriterWithParser(NewRegexpParser())
+	fmt.Fprint(w, "[debug] hidden\n[warning] shown\nshort\n")
+	w.Close()
+
+	assert.Equal(t, []loggedLine{{WarnLevel, "shown"}}, receiveLines(t, lines, 1))
+	select {
+	case entry := <-lines:
+		t.Errorf("unexpected line %q", entry.msg)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+// letterParser reads levels from prefixes like "W: ".
+type letterParser struct{}
+
+func (letterParser) Parse(s *string) (Level, error) {
+	level, _, err := letterParser{}.ParseAndStrip(*s)
+	return level, err
+}
+
+func (letterParser) ParseAndStrip(s string) (Level, string, error) {
+	if len(s) < 3 || s[1:3] != ": " {
+		return DebugLevel, s, fmt.Errorf("no level prefix in %q", s)
+	}
+	level, err := ParseLevel(s[:1])
+	if s[0] == 'W' {
+		level, err = WarnLevel, nil
+	}
+	return level, s[3:], err
+}
+
+func TestWriterWithStrippingParser(t *testing.T) {
+	logger, lines := newEntryChannelLogger("writer", 2)
+	w := logger.WriterWithParser(letterParser{})
+	fmt.Fprint(w, "W: [disk] almost full\nplain\n")
+	w.Close()
+
+	assert.Equal(t, []loggedLine{
+		{WarnLevel, "[disk] almost full"},
+		{InfoLevel, "plain"},
+	}, receiveLines(t, lines, 2))
+}
+
+func TestParsersWithoutPrefix(t *testing.T) {
+	s := "[x"
+	_, err := NewRegexpParser().Parse(&s)
+	assert.Error(t, err)
+	_, err = (&PrefixStrCmp{}).Parse(&s)
+	assert.Error(t, err)
+}